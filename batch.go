@@ -0,0 +1,73 @@
+package randomForest
+
+// PredictBatch predicts every input in parallel. Unlike PredictBatchInto it
+// cannot fail, since it sizes the output itself.
+func (forest *ClassificationForest[F, L]) PredictBatch(inputs [][]F) []L {
+	return predictAll(inputs, forest.Predicate)
+}
+
+// PredictBatchInto predicts every input in parallel into out, which must
+// have the length of inputs.
+func (forest *ClassificationForest[F, L]) PredictBatchInto(inputs [][]F, out []L) error {
+	return predictBatch(inputs, out, forest.Predicate)
+}
+
+// PredictProbaBatch returns PredictProba of every input, computed in
+// parallel. Unlike PredictProbaBatchInto it cannot fail.
+func (forest *ClassificationForest[F, L]) PredictProbaBatch(inputs [][]F) []map[L]float64 {
+	return predictAll(inputs, forest.PredictProba)
+}
+
+// PredictProbaBatchInto is PredictProbaBatch writing into out, which must
+// have the length of inputs.
+func (forest *ClassificationForest[F, L]) PredictProbaBatchInto(inputs [][]F, out []map[L]float64) error {
+	return predictBatch(inputs, out, forest.PredictProba)
+}
+
+// PredictBatch predicts every input in parallel. Unlike PredictBatchInto it
+// cannot fail, since it sizes the output itself.
+func (forest *MongoClassForest[F, L]) PredictBatch(inputs [][]F) []L {
+	return predictAll(inputs, forest.Predicate)
+}
+
+// PredictBatchInto predicts every input in parallel into out, which must
+// have the length of inputs.
+func (forest *MongoClassForest[F, L]) PredictBatchInto(inputs [][]F, out []L) error {
+	return predictBatch(inputs, out, forest.Predicate)
+}
+
+// PredictProbaBatch returns PredictProba of every input, computed in
+// parallel. Unlike PredictProbaBatchInto it cannot fail.
+func (forest *MongoClassForest[F, L]) PredictProbaBatch(inputs [][]F) []map[L]float64 {
+	return predictAll(inputs, forest.PredictProba)
+}
+
+// PredictProbaBatchInto is PredictProbaBatch writing into out, which must
+// have the length of inputs.
+func (forest *MongoClassForest[F, L]) PredictProbaBatchInto(inputs [][]F, out []map[L]float64) error {
+	return predictBatch(inputs, out, forest.PredictProba)
+}
+
+// PredictBatch predicts every input in parallel. Unlike PredictBatchInto it
+// cannot fail, since it sizes the output itself.
+func (forest *RegressionForest[F]) PredictBatch(inputs [][]F) []float64 {
+	return predictAll(inputs, forest.Predicate)
+}
+
+// PredictBatchInto predicts every input in parallel into out, which must
+// have the length of inputs.
+func (forest *RegressionForest[F]) PredictBatchInto(inputs [][]F, out []float64) error {
+	return predictBatch(inputs, out, forest.Predicate)
+}
+
+// PredictBatch predicts every input in parallel. Unlike PredictBatchInto it
+// cannot fail, since it sizes the output itself.
+func (forest *MongoForest[F]) PredictBatch(inputs [][]F) []float64 {
+	return predictAll(inputs, forest.Predicate)
+}
+
+// PredictBatchInto predicts every input in parallel into out, which must
+// have the length of inputs.
+func (forest *MongoForest[F]) PredictBatchInto(inputs [][]F, out []float64) error {
+	return predictBatch(inputs, out, forest.Predicate)
+}
@@ -0,0 +1,29 @@
+package randomForest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPredictBatch(t *testing.T) {
+	withCPUs(t, 4)
+	x, y := loadIris(t)
+	forest := NewClassificationForest[float64, string](1000, 10, 1, 0.5)
+	forest.MaxDepth = 10
+	if err := forest.TryTrain(x, y, 10); err != nil {
+		t.Fatal(err)
+	}
+	proba := forest.PredictProbaBatch(x)
+	for i, p := range proba {
+		if !reflect.DeepEqual(p, forest.PredictProba(x[i])) {
+			t.Fatalf("row %d: batch gives %v, PredictProba %v", i, p, forest.PredictProba(x[i]))
+		}
+	}
+	if got := forest.PredictBatch(x); len(got) != len(x) {
+		t.Fatalf("got %d predictions for %d rows", len(got), len(x))
+	}
+	if err := forest.PredictBatchInto(x, make([]string, 3)); !errors.Is(err, ErrOutputSize) {
+		t.Fatalf("got %v, want %v", err, ErrOutputSize)
+	}
+}
@@ -0,0 +1,220 @@
+package randomForest
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// binning holds the quantile edges of every numeric column and the bin of
+// every training row in it, computed once before the trees grow so nodes
+// build their histograms from small codes instead of searching the edges.
+// Categorical columns get neither.
+type binning[F Feature] struct {
+	edges [][]F
+	codes [][]uint16
+}
+
+// maxBins is the most bins a column may have, so a code fits an uint16.
+const maxBins = math.MaxUint16
+
+// validateBins checks that MaxBins is 0, for exact splits, or leaves room
+// for at least one threshold and fits the codes.
+func (forest *BaseForest[F]) validateBins() error {
+	if forest.MaxBins != 0 && (forest.MaxBins < 2 || forest.MaxBins > maxBins) {
+		return fmt.Errorf("%w: %d, want 0 or 2 to %d", ErrMaxBins, forest.MaxBins, maxBins)
+	}
+	return nil
+}
+
+// computeBins returns at most maxBins quantile edges for every numeric column
+// of data and the bin of every row. Bin b holds the values in
+// (edges[b-1], edges[b]]; missing values get bin 0 and are never read from
+// it.
+func computeBins[F Feature](data [][]F, maxBins int, schema []ColumnType, missing []MissingMarker[F]) *binning[F] {
+	if len(data) == 0 {
+		return nil
+	}
+	cfg := &growConfig[F]{schema: schema, missing: missing}
+	bins := &binning[F]{edges: make([][]F, len(data[0])), codes: make([][]uint16, len(data[0]))}
+	values := make([]F, 0, len(data))
+	for c := range bins.edges {
+		if cfg.columnType(c, data[0][c]) != NUMERIC {
+			continue
+		}
+		marker := cfg.missingMarker(c)
+		values = values[:0]
+		for _, row := range data {
+			if !isMissing(row[c], marker) {
+				values = append(values, row[c])
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		slices.Sort(values)
+		edges := make([]F, 0, maxBins)
+		for b := 1; b <= maxBins; b++ {
+			edge := values[(b*len(values)-1)/maxBins]
+			if len(edges) == 0 || edges[len(edges)-1] != edge {
+				edges = append(edges, edge)
+			}
+		}
+		codes := make([]uint16, len(data))
+		for i, row := range data {
+			if !isMissing(row[c], marker) {
+				b, _ := slices.BinarySearch(edges, row[c])
+				codes[i] = uint16(b)
+			}
+		}
+		bins.edges[c] = edges
+		bins.codes[c] = codes
+	}
+	return bins
+}
+
+// binnedColumn is column c of the samples of a node: sample j is row
+// rows[j] of the binned data and falls into bin codes[rows[j]].
+type binnedColumn[F Feature] struct {
+	edges []F
+	codes []uint16
+	rows  []int
+}
+
+func (b *binnedColumn[F]) bin(j int) int {
+	return int(b.codes[b.rows[j]])
+}
+
+// binned returns column c of the samples at rows, or nil if the column is
+// split exactly.
+func (cfg *growConfig[F]) binned(c int, rows []int) *binnedColumn[F] {
+	if cfg.bins == nil || cfg.bins.edges[c] == nil {
+		return nil
+	}
+	return &binnedColumn[F]{edges: cfg.bins.edges[c], codes: cfg.bins.codes[c], rows: rows}
+}
+
+// getBestHistogramGain accumulates per-bin label counts and only evaluates
+// "<= edge" thresholds on the bin boundaries.
+func getBestHistogramGain[F Feature, L Label](criterion SplitCriterion[L], samples_labels []L, current_entropy float64, bins *binnedColumn[F]) split[F] {
+	var best_value F
+	best_gain := 0.0
+	best_total_r := 0
+	best_total_l := 0
+
+	classes := make(map[L]int)
+	for _, l := range samples_labels {
+		if _, ok := classes[l]; !ok {
+			classes[l] = len(classes)
+		}
+	}
+	index := make([]L, len(classes))
+	for l, k := range classes {
+		index[k] = l
+	}
+
+	edges := bins.edges
+	k := len(classes)
+	hist := make([]float64, (len(edges)+1)*k)
+	sizes := make([]int, len(edges)+1)
+	total := make([]float64, k)
+	for j, l := range samples_labels {
+		b := bins.bin(j)
+		y := classes[l]
+		hist[b*k+y] += 1.0
+		sizes[b] += 1
+		total[y] += 1.0
+	}
+
+	left := make([]float64, k)
+	map_l := make(map[L]float64)
+	map_r := make(map[L]float64)
+	total_l := 0
+	for b := range edges {
+		if sizes[b] == 0 {
+			continue
+		}
+		total_l += sizes[b]
+		total_r := len(samples_labels) - total_l
+		clear(map_l)
+		clear(map_r)
+		for y := 0; y < k; y++ {
+			left[y] += hist[b*k+y]
+			if left[y] > 0 {
+				map_l[index[y]] = left[y]
+			}
+			if total[y]-left[y] > 0 {
+				map_r[index[y]] = total[y] - left[y]
+			}
+		}
+
+		p1 := float64(total_r) / float64(len(samples_labels))
+		p2 := float64(total_l) / float64(len(samples_labels))
+
+		new_entropy := p1*criterion.Impurity(map_r, total_r) + p2*criterion.Impurity(map_l, total_l)
+		entropy_gain := current_entropy - new_entropy
+
+		if beats(entropy_gain, best_gain) {
+			best_gain = entropy_gain
+			best_value = edges[b]
+			best_total_l = total_l
+			best_total_r = total_r
+		}
+	}
+
+	return split[F]{gain: best_gain, value: best_value, totalL: best_total_l, totalR: best_total_r}
+}
+
+func getBestHistogramMSEGain[F Feature](samples_labels []float64, bins *binnedColumn[F]) split[F] {
+	var best_value F
+	best_gain := 0.0
+	best_total_r := 0
+	best_total_l := 0
+
+	edges := bins.edges
+	shift := samples_labels[0]
+	sizes := make([]int, len(edges)+1)
+	sums := make([]float64, len(edges)+1)
+	sqs := make([]float64, len(edges)+1)
+	sum, sq := 0.0, 0.0
+	for j, y := range samples_labels {
+		b := bins.bin(j)
+		d := y - shift
+		sizes[b] += 1
+		sums[b] += d
+		sqs[b] += d * d
+		sum += d
+		sq += d * d
+	}
+	n := float64(len(samples_labels))
+	current_sse := max(sq-sum*sum/n, 0)
+
+	total_l := 0
+	sum_l, sq_l := 0.0, 0.0
+	for b := range edges {
+		if sizes[b] == 0 {
+			continue
+		}
+		total_l += sizes[b]
+		total_r := len(samples_labels) - total_l
+		sum_l += sums[b]
+		sq_l += sqs[b]
+
+		sse_l := sq_l - sum_l*sum_l/float64(total_l)
+		sse_r := 0.0
+		if total_r > 0 {
+			sum_r := sum - sum_l
+			sse_r = (sq - sq_l) - sum_r*sum_r/float64(total_r)
+		}
+		mse_gain := (current_sse - max(sse_l, 0) - max(sse_r, 0)) / n
+
+		if beats(mse_gain, best_gain) {
+			best_gain = mse_gain
+			best_value = edges[b]
+			best_total_l = total_l
+			best_total_r = total_r
+		}
+	}
+
+	return split[F]{gain: best_gain, value: best_value, totalL: best_total_l, totalR: best_total_r}
+}
@@ -0,0 +1,114 @@
+package randomForest
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestComputeBinsCodes(t *testing.T) {
+	x, _ := loadIris(t)
+	x = blank(x, 0.1, math.NaN())
+	bins := computeBins(x, 8, []ColumnType{NUMERIC, NUMERIC, CAT, NUMERIC}, nil)
+	if bins.edges[2] != nil || bins.codes[2] != nil {
+		t.Fatal("categorical column binned")
+	}
+	for _, c := range []int{0, 1, 3} {
+		if len(bins.edges[c]) == 0 || len(bins.edges[c]) > 8 || !slices.IsSorted(bins.edges[c]) {
+			t.Fatalf("column %d: edges %v", c, bins.edges[c])
+		}
+		for i, row := range x {
+			if math.IsNaN(row[c]) {
+				continue
+			}
+			want, _ := slices.BinarySearch(bins.edges[c], row[c])
+			if got := int(bins.codes[c][i]); got != want {
+				t.Fatalf("column %d row %d: code %d, want %d", c, i, got, want)
+			}
+		}
+	}
+}
+
+func TestMaxBinsValidated(t *testing.T) {
+	x, y := loadIris(t)
+	for _, bins := range []int{-1, 1, 65536} {
+		forest := NewClassificationForest[float64, string](1000, 2, 1, 0.5)
+		forest.MaxBins = bins
+		if err := forest.TryTrain(x, y, 2); !errors.Is(err, ErrMaxBins) {
+			t.Fatalf("MaxBins %d: got %v, want %v", bins, err, ErrMaxBins)
+		}
+		regression := NewRegressionForest[float64](1000, 2, 1, 1)
+		regression.MaxBins = bins
+		if err := regression.TryTrain(x, make([]float64, len(x)), 2); !errors.Is(err, ErrMaxBins) {
+			t.Fatalf("MaxBins %d: got %v, want %v", bins, err, ErrMaxBins)
+		}
+	}
+}
+
+func TestBinnedClassifierCloseToExact(t *testing.T) {
+	x, y := syntheticDigits(1500, 32, 1)
+	score := func(maxBins int) float64 {
+		forest := NewClassificationForest[float64, string](1000, 10, 1, 0.2)
+		forest.MaxDepth = 10
+		forest.MaxBins = maxBins
+		forest.Seed = 1
+		if err := forest.TryTrain(x[:1000], y[:1000], 10); err != nil {
+			t.Fatal(err)
+		}
+		return accuracy(forest, x[1000:], y[1000:])
+	}
+	exact, binned := score(0), score(32)
+	if exact < 0.8 || binned < exact-0.03 {
+		t.Fatalf("held-out accuracy %.3f exact, %.3f binned", exact, binned)
+	}
+}
+
+func TestBinnedRegressorCloseToExact(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	x := make([][]float64, 3000)
+	y := make([]float64, len(x))
+	for i := range x {
+		x[i] = []float64{rng.Float64() * 6, rng.Float64()}
+		y[i] = math.Sin(x[i][0]) + rng.NormFloat64()*0.1
+	}
+	mse := func(maxBins int) float64 {
+		forest := NewRegressionForest[float64](2000, 20, 1, 1)
+		forest.MaxBins = maxBins
+		forest.Seed = 1
+		if err := forest.TryTrain(x[:2000], y[:2000], 20); err != nil {
+			t.Fatal(err)
+		}
+		total := 0.0
+		for i := 2000; i < len(x); i++ {
+			d := forest.Predicate(x[i]) - math.Sin(x[i][0])
+			total += d * d
+		}
+		return total / 1000
+	}
+	exact, binned := mse(0), mse(32)
+	if exact > 0.01 || binned > exact+0.005 {
+		t.Fatalf("held-out MSE %.4f exact, %.4f binned", exact, binned)
+	}
+}
+
+func BenchmarkTrainBinned(b *testing.B) {
+	x, y := syntheticDigits(2000, 196, 1)
+	for _, bench := range []struct {
+		name    string
+		maxBins int
+	}{{"exact", 0}, {"binned", 32}} {
+		b.Run(bench.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				forest := NewClassificationForest[float64, string](2000, 5, 1, 0.1)
+				forest.MaxDepth = 10
+				forest.MaxBins = bench.maxBins
+				forest.Seed = 1
+				if err := forest.TryTrain(x, y, 5); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package randomForest
+
+import "math"
+
+// Link maps the summed tree outputs of a BoostedForest to predictions.
+type Link int
+
+const (
+	IDENTITY Link = iota
+	LOGISTIC
+	SOFTMAX
+	EXP
+)
+
+// BoostedForest is an additive ensemble of regression trees as trained by
+// gradient boosting libraries. Tree t adds its leaf value to output
+// t % Outputs; the outputs start at BaseMargin and are averaged instead of
+// summed if Average is set.
+type BoostedForest[F Feature] struct {
+	Features   int
+	Outputs    int
+	Trees      []*RegressionTree[F]
+	BaseMargin []float64
+	Average    bool
+	Link       Link
+}
+
+// Margins returns the raw outputs of input before the link function.
+func (forest *BoostedForest[F]) Margins(input []F) []float64 {
+	margins := make([]float64, forest.Outputs)
+	for t, tree := range forest.Trees {
+		margins[t%forest.Outputs] += tree.Predicate(input)
+	}
+	for k := range margins {
+		if forest.Average {
+			if trees := (len(forest.Trees) - k + forest.Outputs - 1) / forest.Outputs; trees > 0 {
+				margins[k] /= float64(trees)
+			}
+		}
+		if k < len(forest.BaseMargin) {
+			margins[k] += forest.BaseMargin[k]
+		}
+	}
+	return margins
+}
+
+// Transform returns the margins of input after the link function.
+func (forest *BoostedForest[F]) Transform(input []F) []float64 {
+	outputs := forest.Margins(input)
+	switch forest.Link {
+	case LOGISTIC:
+		for k, m := range outputs {
+			outputs[k] = 1 / (1 + math.Exp(-m))
+		}
+	case EXP:
+		for k, m := range outputs {
+			outputs[k] = math.Exp(m)
+		}
+	case SOFTMAX:
+		max := math.Inf(-1)
+		for _, m := range outputs {
+			max = math.Max(max, m)
+		}
+		total := 0.0
+		for k, m := range outputs {
+			outputs[k] = math.Exp(m - max)
+			total += outputs[k]
+		}
+		for k := range outputs {
+			outputs[k] /= total
+		}
+	}
+	return outputs
+}
+
+// Predicate returns the first transformed output: the prediction of a
+// regression model or the positive class probability of a binary one.
+func (forest *BoostedForest[F]) Predicate(input []F) float64 {
+	return forest.Transform(input)[0]
+}
+
+// PredictProba returns the class probabilities of a classification model,
+// indexed by class. A single logistic output gives two classes.
+func (forest *BoostedForest[F]) PredictProba(input []F) []float64 {
+	outputs := forest.Transform(input)
+	if forest.Link == LOGISTIC && forest.Outputs == 1 {
+		return []float64{1 - outputs[0], outputs[0]}
+	}
+	return outputs
+}
+
+// PredictClass returns the index of the most probable class, the first on
+// ties.
+func (forest *BoostedForest[F]) PredictClass(input []F) int {
+	best := 0
+	proba := forest.PredictProba(input)
+	for k, p := range proba {
+		if p > proba[best] {
+			best = k
+		}
+	}
+	return best
+}
+
+// PredictBatchInto predicts every input in parallel into out like
+// Predicate. out must have the length of inputs.
+func (forest *BoostedForest[F]) PredictBatchInto(inputs [][]F, out []float64) error {
+	return predictBatch(inputs, out, forest.Predicate)
+}
@@ -0,0 +1,268 @@
+package randomForest
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+)
+
+type CalibrationMethod int
+
+const (
+	PLATT CalibrationMethod = iota + 1
+	ISOTONIC
+)
+
+// Calibrator maps the forest probability of one class to a calibrated one.
+// Platt scaling uses 1/(1+exp(A*p+B)); isotonic regression interpolates
+// linearly between the points X, Y.
+type Calibrator struct {
+	Method CalibrationMethod
+	A      float64   `json:",omitempty" bson:",omitempty"`
+	B      float64   `json:",omitempty" bson:",omitempty"`
+	X      []float64 `json:",omitempty" bson:",omitempty"`
+	Y      []float64 `json:",omitempty" bson:",omitempty"`
+}
+
+func (c *Calibrator) apply(p float64) float64 {
+	if c.Method == PLATT {
+		return 1 / (1 + math.Exp(c.A*p+c.B))
+	}
+	if len(c.X) == 0 {
+		return p
+	}
+	k := sort.SearchFloat64s(c.X, p)
+	if k == 0 {
+		return c.Y[0]
+	}
+	if k == len(c.X) {
+		return c.Y[len(c.Y)-1]
+	}
+	t := (p - c.X[k-1]) / (c.X[k] - c.X[k-1])
+	return c.Y[k-1] + t*(c.Y[k]-c.Y[k-1])
+}
+
+// fitCalibrator fits a calibrator to scores with binary outcomes. The
+// method must have been checked by validMethod.
+func fitCalibrator(method CalibrationMethod, scores []float64, outcomes []bool) *Calibrator {
+	if method == ISOTONIC {
+		return fitIsotonic(scores, outcomes)
+	}
+	return fitPlatt(scores, outcomes)
+}
+
+func validMethod(method CalibrationMethod) error {
+	if method != PLATT && method != ISOTONIC {
+		return fmt.Errorf("%w: %d", ErrUnknownMethod, method)
+	}
+	return nil
+}
+
+// fitPlatt fits A and B by Newton's method with backtracking on the
+// regularized targets of Platt (1999), following Lin, Lin and Weng (2007).
+func fitPlatt(scores []float64, outcomes []bool) *Calibrator {
+	positives := 0
+	for _, y := range outcomes {
+		if y {
+			positives++
+		}
+	}
+	negatives := len(outcomes) - positives
+	hi := (float64(positives) + 1) / (float64(positives) + 2)
+	lo := 1 / (float64(negatives) + 2)
+	targets := make([]float64, len(outcomes))
+	for i, y := range outcomes {
+		targets[i] = lo
+		if y {
+			targets[i] = hi
+		}
+	}
+
+	a, b := 0.0, math.Log((float64(negatives)+1)/(float64(positives)+1))
+	loss := func(a, b float64) float64 {
+		total := 0.0
+		for i, s := range scores {
+			f := s*a + b
+			if f >= 0 {
+				total += targets[i]*f + math.Log1p(math.Exp(-f))
+			} else {
+				total += (targets[i]-1)*f + math.Log1p(math.Exp(f))
+			}
+		}
+		return total
+	}
+	current := loss(a, b)
+	for iter := 0; iter < 100; iter++ {
+		h11, h22, h21, g1, g2 := 1e-12, 1e-12, 0.0, 0.0, 0.0
+		for i, s := range scores {
+			f := s*a + b
+			var p, q float64
+			if f >= 0 {
+				p = math.Exp(-f) / (1 + math.Exp(-f))
+				q = 1 / (1 + math.Exp(-f))
+			} else {
+				p = 1 / (1 + math.Exp(f))
+				q = math.Exp(f) / (1 + math.Exp(f))
+			}
+			d2 := p * q
+			h11 += s * s * d2
+			h22 += d2
+			h21 += s * d2
+			d1 := targets[i] - p
+			g1 += s * d1
+			g2 += d1
+		}
+		if math.Abs(g1) < 1e-5 && math.Abs(g2) < 1e-5 {
+			break
+		}
+		det := h11*h22 - h21*h21
+		da := -(h22*g1 - h21*g2) / det
+		db := -(-h21*g1 + h11*g2) / det
+		gd := g1*da + g2*db
+		step := 1.0
+		for step >= 1e-10 {
+			next := loss(a+step*da, b+step*db)
+			if next < current+1e-4*step*gd {
+				a, b, current = a+step*da, b+step*db, next
+				break
+			}
+			step /= 2
+		}
+		if step < 1e-10 {
+			break
+		}
+	}
+	return &Calibrator{Method: PLATT, A: a, B: b}
+}
+
+// fitIsotonic fits a non-decreasing step function with the pool adjacent
+// violators algorithm and keeps one point per block at its mean score.
+func fitIsotonic(scores []float64, outcomes []bool) *Calibrator {
+	order := allRows(len(scores))
+	slices.SortFunc(order, func(a, b int) int {
+		return cmp.Compare(scores[a], scores[b])
+	})
+	type block struct {
+		x, y, n float64
+	}
+	blocks := make([]block, 0, len(order))
+	for _, i := range order {
+		b := block{x: scores[i], n: 1}
+		if outcomes[i] {
+			b.y = 1
+		}
+		for len(blocks) > 0 {
+			last := blocks[len(blocks)-1]
+			if last.y/last.n < b.y/b.n && last.x/last.n != b.x/b.n {
+				break
+			}
+			b = block{x: last.x + b.x, y: last.y + b.y, n: last.n + b.n}
+			blocks = blocks[:len(blocks)-1]
+		}
+		blocks = append(blocks, b)
+	}
+	c := &Calibrator{Method: ISOTONIC, X: make([]float64, len(blocks)), Y: make([]float64, len(blocks))}
+	for k, b := range blocks {
+		c.X[k] = b.x / b.n
+		c.Y[k] = b.y / b.n
+	}
+	return c
+}
+
+// calibrate applies one calibrator per class and rescales the result to sum
+// to 1. Classes without a calibrator keep their probability.
+func calibrate[L Label](proba map[L]float64, calibration map[L]*Calibrator) map[L]float64 {
+	if len(calibration) == 0 {
+		return proba
+	}
+	result := make(map[L]float64, len(calibration))
+	total := 0.0
+	for l, c := range calibration {
+		result[l] = c.apply(proba[l])
+		total += result[l]
+	}
+	for l, p := range proba {
+		if _, ok := calibration[l]; !ok {
+			result[l] = p
+			total += p
+		}
+	}
+	if total <= 0 {
+		return proba
+	}
+	for l := range result {
+		result[l] /= total
+	}
+	return result
+}
+
+// PredictProba returns the class probabilities of input, summing to 1 and
+// calibrated if the forest has been calibrated.
+func (forest *ClassificationForest[F, L]) PredictProba(input []F) map[L]float64 {
+	return calibrate(probabilities(forest.PredicateWithData(input)), forest.Calibration)
+}
+
+// PredictProba returns the class probabilities of input, summing to 1. They
+// are never calibrated: a MongoClassForest keeps no training rows to fit
+// calibrators on and does not store them.
+func (forest *MongoClassForest[F, L]) PredictProba(input []F) map[L]float64 {
+	return probabilities(forest.PredicateWithData(input))
+}
+
+// Calibrate fits one calibrator per class on the out-of-bag predictions and
+// stores them with the forest, replacing earlier ones. The method must be
+// PLATT or ISOTONIC.
+func (forest *ClassificationForest[F, L]) Calibrate(method CalibrationMethod) error {
+	if err := validMethod(method); err != nil {
+		return err
+	}
+	var scores []map[L]float64
+	var labels []L
+	for i, votes := range forest.OOBPredictions() {
+		if votes != nil {
+			scores = append(scores, votes)
+			labels = append(labels, forest.Labels[i])
+		}
+	}
+	if len(scores) == 0 {
+		return ErrNoOOB
+	}
+	forest.fitCalibration(method, scores, labels)
+	return nil
+}
+
+// CalibrateOn fits the calibrators on a held-out set instead of the
+// out-of-bag predictions.
+func (forest *ClassificationForest[F, L]) CalibrateOn(inputs [][]F, labels []L, method CalibrationMethod) error {
+	if err := validMethod(method); err != nil {
+		return err
+	}
+	if err := validateInputs(inputs, len(labels), forest.Features); err != nil {
+		return err
+	}
+	scores := make([]map[L]float64, len(inputs))
+	for i, input := range inputs {
+		scores[i] = probabilities(forest.PredicateWithData(input))
+	}
+	forest.fitCalibration(method, scores, labels)
+	return nil
+}
+
+func (forest *ClassificationForest[F, L]) fitCalibration(method CalibrationMethod, scores []map[L]float64, labels []L) {
+	classes := make(map[L]bool)
+	for _, l := range labels {
+		classes[l] = true
+	}
+	forest.Calibration = make(map[L]*Calibrator, len(classes))
+	p := make([]float64, len(scores))
+	outcomes := make([]bool, len(scores))
+	for _, l := range sortedKeys(classes) {
+		for i := range scores {
+			p[i] = scores[i][l]
+			outcomes[i] = labels[i] == l
+		}
+		forest.Calibration[l] = fitCalibrator(method, p, outcomes)
+	}
+}
@@ -0,0 +1,112 @@
+package randomForest
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// noisyScores returns scores with outcomes that are true with probability
+// score squared, so an uncalibrated score is too high.
+func noisyScores() ([]float64, []bool) {
+	rng := rand.New(rand.NewSource(1))
+	scores := make([]float64, 500)
+	outcomes := make([]bool, len(scores))
+	for i := range scores {
+		scores[i] = rng.Float64()
+		outcomes[i] = rng.Float64() < scores[i]*scores[i]
+	}
+	return scores, outcomes
+}
+
+func TestPlattMonotone(t *testing.T) {
+	c := fitPlatt(noisyScores())
+	previous := -1.0
+	for p := 0.0; p <= 1; p += 0.01 {
+		q := c.apply(p)
+		if q < 0 || q > 1 || q < previous {
+			t.Fatalf("Platt maps %v to %v after %v", p, q, previous)
+		}
+		previous = q
+	}
+	if c.apply(0) > 0.2 || c.apply(1) < 0.6 {
+		t.Fatalf("Platt maps 0 to %v and 1 to %v", c.apply(0), c.apply(1))
+	}
+}
+
+func TestIsotonicNonDecreasing(t *testing.T) {
+	c := fitIsotonic(noisyScores())
+	for k := 1; k < len(c.X); k++ {
+		if c.X[k] <= c.X[k-1] || c.Y[k] < c.Y[k-1] {
+			t.Fatalf("point %d: (%v, %v) after (%v, %v)", k, c.X[k], c.Y[k], c.X[k-1], c.Y[k-1])
+		}
+	}
+	previous := 0.0
+	for p := 0.0; p <= 1; p += 0.01 {
+		q := c.apply(p)
+		if q < previous || q > 1 {
+			t.Fatalf("isotonic maps %v to %v after %v", p, q, previous)
+		}
+		previous = q
+	}
+}
+
+// The 1 at 0.2 and the 0 at 0.3 violate the order and pool into one block
+// at their mean score.
+func TestIsotonicPools(t *testing.T) {
+	c := fitIsotonic([]float64{0.3, 0.1, 0.4, 0.2}, []bool{false, false, true, true})
+	if want := []float64{0.1, 0.25, 0.4}; !reflect.DeepEqual(c.X, want) {
+		t.Fatalf("got X %v, want %v", c.X, want)
+	}
+	if want := []float64{0, 0.5, 1}; !reflect.DeepEqual(c.Y, want) {
+		t.Fatalf("got Y %v, want %v", c.Y, want)
+	}
+	if got := c.apply(0.175); math.Abs(got-0.25) > 1e-12 {
+		t.Fatalf("got %v between the first two points, want 0.25", got)
+	}
+}
+
+func TestCalibrateUnknownMethod(t *testing.T) {
+	x, y := loadIris(t)
+	forest := trainedIris(t, x, y)
+	for _, method := range []CalibrationMethod{0, ISOTONIC + 1} {
+		if err := forest.Calibrate(method); !errors.Is(err, ErrUnknownMethod) {
+			t.Fatalf("Calibrate(%d): got %v, want %v", method, err, ErrUnknownMethod)
+		}
+		if err := forest.CalibrateOn(x, y, method); !errors.Is(err, ErrUnknownMethod) {
+			t.Fatalf("CalibrateOn(%d): got %v, want %v", method, err, ErrUnknownMethod)
+		}
+	}
+	if forest.Calibration != nil {
+		t.Fatalf("an unknown method left calibrators %v", forest.Calibration)
+	}
+}
+
+func TestCalibrationSaved(t *testing.T) {
+	x, y := loadIris(t)
+	for _, method := range []CalibrationMethod{PLATT, ISOTONIC} {
+		forest := trainedIris(t, x, y)
+		if err := forest.Calibrate(method); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := forest.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		loaded := &ClassificationForest[float64, string]{}
+		if err := loaded.Load(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(loaded.Calibration, forest.Calibration) {
+			t.Fatalf("method %d: calibrators not restored", method)
+		}
+		for i, row := range x {
+			if got, want := loaded.PredictProba(row), forest.PredictProba(row); !sameVotes(got, want) {
+				t.Fatalf("row %d: got %v after loading, want %v", i, got, want)
+			}
+		}
+	}
+}
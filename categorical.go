@@ -0,0 +1,150 @@
+package randomForest
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Multi-class nodes try every partition of up to this many categories and
+// fall back to ordering the categories otherwise.
+const maxExhaustiveCategories = 10
+
+// getBestCategoricalGain searches binary partitions of the categories of
+// column c. Categories are ordered by their share of the majority class and
+// only the prefixes of that order are evaluated, which is optimal for two
+// classes (Breiman). Multi-class nodes with few categories are searched
+// exhaustively instead.
+func getBestCategoricalGain[F Feature, L Label](criterion SplitCriterion[L], samples [][]F, c int, samples_labels []L, current_entropy float64) split[F] {
+	counts := make(map[F]map[L]float64)
+	sizes := make(map[F]int)
+	total := make(map[L]float64)
+	for j, row := range samples {
+		v := row[c]
+		if counts[v] == nil {
+			counts[v] = make(map[L]float64)
+		}
+		counts[v][samples_labels[j]] += 1.0
+		sizes[v] += 1
+		total[samples_labels[j]] += 1.0
+	}
+	categories := sortedKeys(sizes)
+	best := split[F]{}
+	if len(categories) < 2 {
+		return best
+	}
+
+	evaluate := func(left []F, map_l map[L]float64, total_l int) {
+		map_r := make(map[L]float64, len(total))
+		for l, v := range total {
+			if r := v - map_l[l]; r > 0 {
+				map_r[l] = r
+			}
+		}
+		total_r := len(samples) - total_l
+
+		p1 := float64(total_r) / float64(len(samples))
+		p2 := float64(total_l) / float64(len(samples))
+
+		new_entropy := p1*criterion.Impurity(map_r, total_r) + p2*criterion.Impurity(map_l, total_l)
+		entropy_gain := current_entropy - new_entropy
+
+		if beats(entropy_gain, best.gain) {
+			best = split[F]{gain: entropy_gain, categories: sortedCopy(left), totalL: total_l, totalR: total_r}
+		}
+	}
+
+	if len(total) > 2 && len(categories) <= maxExhaustiveCategories {
+		// the last category always stays right, so every partition is seen once
+		for mask := 1; mask < 1<<(len(categories)-1); mask++ {
+			left := make([]F, 0, len(categories))
+			map_l := make(map[L]float64)
+			total_l := 0
+			for i, v := range categories {
+				if mask&(1<<i) == 0 {
+					continue
+				}
+				left = append(left, v)
+				for l, n := range counts[v] {
+					map_l[l] += n
+				}
+				total_l += sizes[v]
+			}
+			evaluate(left, map_l, total_l)
+		}
+		return best
+	}
+
+	var majority L
+	for _, l := range sortedKeys(total) {
+		if total[l] > total[majority] {
+			majority = l
+		}
+	}
+	slices.SortStableFunc(categories, func(a, b F) int {
+		return cmp.Compare(counts[a][majority]/float64(sizes[a]), counts[b][majority]/float64(sizes[b]))
+	})
+
+	map_l := make(map[L]float64)
+	total_l := 0
+	for i, v := range categories[:len(categories)-1] {
+		for l, n := range counts[v] {
+			map_l[l] += n
+		}
+		total_l += sizes[v]
+		evaluate(categories[:i+1], map_l, total_l)
+	}
+	return best
+}
+
+// getBestCategoricalMSEGain orders the categories of column c by their mean
+// label; the best partition is then a prefix of that order.
+func getBestCategoricalMSEGain[F Feature](samples [][]F, c int, samples_labels []float64) split[F] {
+	shift := samples_labels[0]
+	sizes := make(map[F]int)
+	sums := make(map[F]float64)
+	sqs := make(map[F]float64)
+	sum, sq := 0.0, 0.0
+	for j, row := range samples {
+		d := samples_labels[j] - shift
+		sizes[row[c]] += 1
+		sums[row[c]] += d
+		sqs[row[c]] += d * d
+		sum += d
+		sq += d * d
+	}
+	categories := sortedKeys(sizes)
+	best := split[F]{}
+	if len(categories) < 2 {
+		return best
+	}
+	slices.SortStableFunc(categories, func(a, b F) int {
+		return cmp.Compare(sums[a]/float64(sizes[a]), sums[b]/float64(sizes[b]))
+	})
+
+	n := float64(len(samples))
+	current_sse := max(sq-sum*sum/n, 0)
+	total_l := 0
+	sum_l, sq_l := 0.0, 0.0
+	for i, v := range categories[:len(categories)-1] {
+		total_l += sizes[v]
+		total_r := len(samples) - total_l
+		sum_l += sums[v]
+		sq_l += sqs[v]
+
+		sum_r := sum - sum_l
+		sse_l := sq_l - sum_l*sum_l/float64(total_l)
+		sse_r := (sq - sq_l) - sum_r*sum_r/float64(total_r)
+		mse_gain := (current_sse - max(sse_l, 0) - max(sse_r, 0)) / n
+
+		if beats(mse_gain, best.gain) {
+			best = split[F]{gain: mse_gain, categories: sortedCopy(categories[:i+1]), totalL: total_l, totalR: total_r}
+		}
+	}
+	return best
+}
+
+func sortedCopy[F Feature](values []F) []F {
+	out := slices.Clone(values)
+	slices.Sort(out)
+	return out
+}
@@ -0,0 +1,165 @@
+package randomForest
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// randomCategorical returns n rows of one categorical column with the given
+// number of categories, each with its own random label distribution.
+func randomCategorical(rng *rand.Rand, n, categories, classes int) ([][]string, []string) {
+	weights := make([][]float64, categories)
+	for v := range weights {
+		weights[v] = make([]float64, classes)
+		for l := range weights[v] {
+			weights[v][l] = rng.Float64()
+		}
+	}
+	x := make([][]string, n)
+	y := make([]string, n)
+	for i := range x {
+		v := rng.Intn(categories)
+		x[i] = []string{strconv.Itoa(v)}
+		total := 0.0
+		for _, w := range weights[v] {
+			total += w
+		}
+		r := rng.Float64() * total
+		l := 0
+		for r > weights[v][l] && l < classes-1 {
+			r -= weights[v][l]
+			l++
+		}
+		y[i] = strconv.Itoa(l)
+	}
+	return x, y
+}
+
+// bruteForceGain scores every subset of the categories of column 0 sent left.
+func bruteForceGain[L Label](criterion SplitCriterion[L], x [][]string, y []L, current float64) float64 {
+	categories := uniqueValues(x, 0)
+	best := 0.0
+	for mask := 1; mask < 1<<len(categories)-1; mask++ {
+		map_l := make(map[L]float64)
+		map_r := make(map[L]float64)
+		total_l := 0
+		for j, row := range x {
+			k, _ := slices.BinarySearch(categories, row[0])
+			if mask&(1<<k) != 0 {
+				map_l[y[j]]++
+				total_l++
+			} else {
+				map_r[y[j]]++
+			}
+		}
+		total_r := len(x) - total_l
+		p1 := float64(total_r) / float64(len(x))
+		p2 := float64(total_l) / float64(len(x))
+		best = max(best, current-(p1*criterion.Impurity(map_r, total_r)+p2*criterion.Impurity(map_l, total_l)))
+	}
+	return best
+}
+
+func impurity[L Label](criterion SplitCriterion[L], y []L) float64 {
+	counts := make(map[L]float64)
+	for _, l := range y {
+		counts[l]++
+	}
+	return criterion.Impurity(counts, len(y))
+}
+
+func testCategoricalGain(t *testing.T, rounds, categories, classes int) {
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < rounds; round++ {
+		x, y := randomCategorical(rng, 200, categories, classes)
+		for _, criterion := range []SplitCriterion[string]{Gini[string]{}, Entropy[string]{}} {
+			current := impurity(criterion, y)
+			s := getBestCategoricalGain(criterion, x, 0, y, current)
+			if want := bruteForceGain(criterion, x, y, current); math.Abs(s.gain-want) > 1e-12 {
+				t.Fatalf("round %d, %T: gain %v, best subset gains %v", round, criterion, s.gain, want)
+			}
+			left := 0
+			for _, row := range x {
+				if _, ok := slices.BinarySearch(s.categories, row[0]); ok {
+					left++
+				}
+			}
+			if left != s.totalL || len(x)-left != s.totalR {
+				t.Fatalf("round %d: categories %v hold %d rows, split says %d", round, s.categories, left, s.totalL)
+			}
+		}
+	}
+}
+
+// With two classes ordering the categories by their share of one class finds
+// the best subset (Breiman).
+func TestCategoricalTwoClasses(t *testing.T) {
+	testCategoricalGain(t, 50, 8, 2)
+}
+
+func TestCategoricalExhaustive(t *testing.T) {
+	testCategoricalGain(t, 10, maxExhaustiveCategories, 3)
+}
+
+// Ordering the categories by their mean label finds the subset with the
+// least squared error.
+func TestCategoricalRegression(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < 50; round++ {
+		means := make([]float64, 8)
+		for v := range means {
+			means[v] = rng.NormFloat64()
+		}
+		x := make([][]string, 200)
+		y := make([]float64, len(x))
+		for i := range x {
+			v := rng.Intn(len(means))
+			x[i] = []string{strconv.Itoa(v)}
+			y[i] = means[v] + rng.NormFloat64()
+		}
+		categories := uniqueValues(x, 0)
+		want := 0.0
+		for mask := 1; mask < 1<<len(categories)-1; mask++ {
+			var left, right []float64
+			for j, row := range x {
+				if k, _ := slices.BinarySearch(categories, row[0]); mask&(1<<k) != 0 {
+					left = append(left, y[j])
+				} else {
+					right = append(right, y[j])
+				}
+			}
+			p1 := float64(len(right)) / float64(len(x))
+			p2 := float64(len(left)) / float64(len(x))
+			want = max(want, getMSE(y)-(p1*getMSE(right)+p2*getMSE(left)))
+		}
+		if s := getBestCategoricalMSEGain(x, 0, y); math.Abs(s.gain-want) > 1e-9 {
+			t.Fatalf("round %d: gain %v, best subset gains %v", round, s.gain, want)
+		}
+	}
+}
+
+// A category the forest never saw is in no Categories list and goes right.
+func TestCategoricalUnseenGoesRight(t *testing.T) {
+	x := [][]string{{"a"}, {"a"}, {"b"}, {"b"}, {"c"}, {"c"}}
+	y := []string{"yes", "yes", "no", "no", "yes", "yes"}
+	forest := NewClassificationForest[string, string](100, 1, 1, 1)
+	forest.MaxDepth = 1
+	forest.Seed = 1
+	if err := forest.TryTrain(x, y, 1); err != nil {
+		t.Fatal(err)
+	}
+	root := forest.Trees[0].Root
+	if root.isLeaf() {
+		t.Fatal("no split on the categories")
+	}
+	unseen := []string{"z"}
+	if _, ok := slices.BinarySearch(root.Categories, "z"); ok || root.goesLeft(unseen) {
+		t.Fatalf("unseen category goes left of %v", root.Categories)
+	}
+	if got, want := forest.Trees[0].Predicate(unseen), root.Right.Labels; !sameVotes(got, want) {
+		t.Fatalf("got %v for an unseen category, want the right leaf %v", got, want)
+	}
+}
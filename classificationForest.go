@@ -2,14 +2,11 @@ package randomForest
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"math"
 	"math/rand"
 	"os"
 	"runtime"
-	"sync"
-	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -48,22 +45,89 @@ type BaseForest[F Feature] struct {
 	BufferSize      int
 	TreeLimit       int
 	MaxDepth        int
+	Seed            int64 // 0 is unset: the first training draws a random seed and stores it
+	MaxBins         int   // 0 for exact splits, else 2 to 65535 quantile bins
+	Schema          []ColumnType
+	Missing         []MissingMarker[F]
+	MaxSurrogates   int
+	// Encoder, if set, is the RowEncoder the training rows came from. It is
+	// saved with the forest so rows can be encoded for prediction after
+	// loading it.
+	Encoder  *RowEncoder      `json:",omitempty" bson:",omitempty"`
+	Progress ProgressReporter `json:"-" bson:"-"`
+	bins     *binning[F]
+	evicted  int
+}
+
+func appendBuffer[E any](buffer []E, items []E, size int) []E {
+	buffer = append(buffer, items...)
+	if len(buffer) > size {
+		buffer = shiftLeft(buffer, len(buffer)-size)
+	}
+	return buffer
+}
+
+// appendData buffers inputs and counts the rows pushed out of the buffer, so
+// out-of-bag rows recorded by earlier trees can still be located in Data.
+func (forest *BaseForest[F]) appendData(inputs [][]F) {
+	n := len(forest.Data) + len(inputs)
+	forest.Data = appendBuffer(forest.Data, inputs, forest.BufferSize)
+	forest.evicted += n - len(forest.Data)
+}
+
+func (forest *BaseForest[F]) growConfig(ctx context.Context, rng *rand.Rand, mFeatures int) *growConfig[F] {
+	return &growConfig[F]{
+		ctx:           ctx,
+		rng:           rng,
+		mFeatures:     mFeatures,
+		schema:        forest.Schema,
+		bins:          forest.bins,
+		missing:       forest.Missing,
+		maxSurrogates: forest.MaxSurrogates,
+	}
+}
+
+func (forest *BaseForest[F]) setSizes(features int) {
+	forest.Features = features
+	forest.MFeatures = min(max(int(float64(features)*forest.MFeaturesFactor), 1), features)
+	forest.NSize = max(int(float64(len(forest.Data))*forest.NSizeFactor), 1)
+	forest.bins = nil
+	if forest.MaxBins > 0 {
+		forest.bins = computeBins(forest.Data, forest.MaxBins, forest.Schema, forest.Missing)
+	}
 }
 
 type ClassificationForest[F Feature, L Label] struct {
 	*BaseForest[F]
-	Trees   []*ClassificationTree[F, L]
-	Labels  []L `json:"-"`
-	Classes int
+	Trees       []*ClassificationTree[F, L]
+	Labels      []L `json:"-"`
+	Classes     int
+	Criterion   SplitCriterion[L] `json:"-" bson:"-"`
+	Calibration map[L]*Calibrator `json:",omitempty"`
 }
 
 type MongoClassForest[F Feature, L Label] struct {
 	*BaseForest[F]
-	Trees    []*ClassificationTree[F, L]
-	Labels   []L `json:"-"`
-	Classes  int
-	Game     string
-	database *mongo.Database `json:"-"`
+	Trees     []*ClassificationTree[F, L]
+	Labels    []L `json:"-"`
+	Classes   int
+	Game      string
+	Criterion SplitCriterion[L] `json:"-" bson:"-"`
+	database  *mongo.Database   `json:"-"`
+}
+
+func (forest *ClassificationForest[F, L]) criterion() SplitCriterion[L] {
+	if forest.Criterion == nil {
+		return Entropy[L]{}
+	}
+	return forest.Criterion
+}
+
+func (forest *MongoClassForest[F, L]) criterion() SplitCriterion[L] {
+	if forest.Criterion == nil {
+		return Entropy[L]{}
+	}
+	return forest.Criterion
 }
 
 func maxLabel[L Label](votes map[L]float64) (L, float64) {
@@ -79,6 +143,12 @@ func maxLabel[L Label](votes map[L]float64) (L, float64) {
 	return maxLabel, maxValue
 }
 
+// MeanDecreaseImpurity weighs each feature by how much its splits reduce the
+// split criterion; see meanDecreaseImpurity.
+func (f ClassificationForest[T, L]) MeanDecreaseImpurity() []float64 {
+	return meanDecreaseImpurity(f.Trees, f.Features)
+}
+
 func (f ClassificationForest[T, L]) Importance() []float64 {
 	imp := make([]float64, f.Features)
 	for i := 0; i < len(f.Trees); i++ {
@@ -93,6 +163,11 @@ func (f ClassificationForest[T, L]) Importance() []float64 {
 	return imp
 }
 
+// MeanDecreaseImpurity works like ClassificationForest.MeanDecreaseImpurity.
+func (f MongoClassForest[T, L]) MeanDecreaseImpurity() []float64 {
+	return meanDecreaseImpurity(f.Trees, f.Features)
+}
+
 func (f MongoClassForest[T, L]) Importance() []float64 {
 	imp := make([]float64, f.Features)
 	for i := 0; i < len(f.Trees); i++ {
@@ -131,134 +206,201 @@ func NewMongoClassForest[F Feature, L Label](database *mongo.Database, treeCount
 }
 
 func (forest *ClassificationForest[F, L]) Train(inputs [][]F, labels []L, treesAmount int) {
-	forest.Data = append(forest.Data, inputs...)
-	if len(forest.Data) > forest.BufferSize {
-		forest.Data = shiftLeft(forest.Data, len(forest.Data)-forest.BufferSize)
+	if err := forest.TryTrain(inputs, labels, treesAmount); err != nil {
+		panic(err)
+	}
+}
+
+func (forest *ClassificationForest[F, L]) TryTrain(inputs [][]F, labels []L, treesAmount int) error {
+	return forest.TrainContext(context.Background(), inputs, labels, treesAmount)
+}
+
+// TrainContext adds treesAmount trees trained on the stored rows plus inputs.
+// The inputs are appended to Data and Labels before training starts and stay
+// there if ctx is cancelled; the trees finished by then are kept too, and
+// ctx.Err() is returned.
+func (forest *ClassificationForest[F, L]) TrainContext(ctx context.Context, inputs [][]F, labels []L, treesAmount int) error {
+	features := 0
+	if len(forest.Data) > 0 {
+		features = forest.Features
+	}
+	if err := validateInputs(inputs, len(labels), features); err != nil {
+		return err
+	}
+	if err := forest.validateSchema(len(inputs[0])); err != nil {
+		return err
 	}
-	forest.Labels = append(forest.Labels, labels...)
-	if len(forest.Labels) > forest.BufferSize {
-		forest.Labels = shiftLeft(forest.Labels, len(forest.Labels)-forest.BufferSize)
+	if err := forest.validateBins(); err != nil {
+		return err
 	}
+	forest.appendData(inputs)
+	forest.Labels = appendBuffer(forest.Labels, labels, forest.BufferSize)
 	classMap := make(map[L]bool)
 	for _, c := range forest.Labels {
-		if _, ok := classMap[c]; !ok {
-			forest.Classes += 1
-			classMap[c] = true
-		}
+		classMap[c] = true
 	}
+	forest.Classes = len(classMap)
+	forest.setSizes(len(inputs[0]))
+
+	seed := forest.trainingSeed()
+	offset := len(forest.Trees)
+	trees, err := buildTrees(ctx, forest.progress(), treesAmount, func(ctx context.Context, x int) (*ClassificationTree[F, L], error) {
+		return forest.buildTree(ctx, treeRand(seed, offset+x))
+	})
+	forest.Trees = append(forest.Trees, trees...)
+	return err
+}
 
-	forest.Features = len(inputs[0])
-	forest.MFeatures = int(float64(forest.Features) * forest.MFeaturesFactor)
-	forest.NSize = int(float64(len(forest.Data)) * forest.NSizeFactor)
-	prog_counter := 0
-	mutex := &sync.Mutex{}
-	s := make(chan bool, NUM_CPU)
-	for i := 0; i < treesAmount; i++ {
-		s <- true
-		go func(x int) {
-			defer func() { <-s }()
-
-			fmt.Printf(">> %v buiding %vth tree...\n", time.Now(), x)
-			tree := forest.BuildTree()
-			forest.Trees = append(forest.Trees, tree)
-			//fmt.Printf("<< %v the %vth tree is done.\n",time.Now(), x)
-			mutex.Lock()
-			prog_counter += 1
-			fmt.Printf("%v tranning progress %.0f%%\n", time.Now(), float64(prog_counter)/float64(treesAmount)*100)
-			mutex.Unlock()
-		}(i)
+func (forest *MongoClassForest[F, L]) Train() {
+	if err := forest.TryTrain(); err != nil {
+		panic(err)
 	}
+}
 
-	for i := 0; i < NUM_CPU; i++ {
-		s <- true
-	}
+func (forest *MongoClassForest[F, L]) TryTrain() error {
+	return forest.TrainContext(context.Background())
 }
 
-func (forest *MongoClassForest[F, L]) Train() {
-	prog_counter := 0
-	mutex := &sync.Mutex{}
-	s := make(chan bool, NUM_CPU)
-	for i := 0; i < forest.TreeLimit; i++ {
-		s <- true
-		go func(x int) {
-			defer func() { <-s }()
+// TrainContext adds TreeLimit trees. If ctx is cancelled it keeps the trees
+// finished by then and returns ctx.Err().
+func (forest *MongoClassForest[F, L]) TrainContext(ctx context.Context) error {
+	seed := forest.trainingSeed()
+	offset := len(forest.Trees)
+	trees, err := buildTrees(ctx, forest.progress(), forest.TreeLimit, func(ctx context.Context, x int) (*ClassificationTree[F, L], error) {
+		return forest.buildTree(ctx, treeRand(seed, offset+x))
+	})
+	forest.Trees = append(forest.Trees, trees...)
+	return err
+}
 
-			fmt.Printf(">> %v buiding %vth tree...\n", time.Now(), x)
-			tree := forest.BuildTree()
-			forest.Trees = append(forest.Trees, tree)
-			//fmt.Printf("<< %v the %vth tree is done.\n",time.Now(), x)
-			mutex.Lock()
-			prog_counter += 1
-			fmt.Printf("%v tranning progress %.0f%%\n", time.Now(), float64(prog_counter)/float64(forest.TreeLimit)*100)
-			mutex.Unlock()
-		}(i)
+func (forest *ClassificationForest[F, L]) BuildTree() *ClassificationTree[F, L] {
+	tree, err := forest.TryBuildTree()
+	if err != nil {
+		panic(err)
 	}
+	return tree
+}
 
-	for i := 0; i < NUM_CPU; i++ {
-		s <- true
-	}
+func (forest *ClassificationForest[F, L]) TryBuildTree() (*ClassificationTree[F, L], error) {
+	return forest.buildTree(context.Background(), newRand())
 }
 
-func (forest *ClassificationForest[F, L]) BuildTree() *ClassificationTree[F, L] {
+func (forest *ClassificationForest[F, L]) buildTree(ctx context.Context, rng *rand.Rand) (*ClassificationTree[F, L], error) {
+	if len(forest.Data) == 0 || forest.NSize == 0 {
+		return nil, ErrEmptyInput
+	}
 	samples := make([][]F, forest.NSize)
 	samples_labels := make([]L, forest.NSize)
+	rows := make([]int, forest.NSize)
 	used := make([]bool, len(forest.Data))
 	for i := 0; i < forest.NSize; i++ {
-		j := rand.Intn(len(forest.Data))
+		j := rng.Intn(len(forest.Data))
 		samples[i] = forest.Data[j]
+		rows[i] = j
 		samples_labels[i] = forest.Labels[j]
-		used[i] = true
+		used[j] = true
 	}
 
-	tree := &ClassificationTree[F, L]{}
-	tree.Root = buildNode(samples, samples_labels, forest.MFeatures, forest.MaxDepth)
-	count := 0
+	tree := &ClassificationTree[F, L]{oobRows: forest.oobRows(used)}
+	cfg := forest.growConfig(ctx, rng, forest.MFeatures)
+	tree.Root = buildNode(cfg, forest.criterion(), samples, rows, samples_labels, forest.MaxDepth)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	e := 0.0
-	for i := 0; i < len(forest.Data); i++ {
-		if !used[i] {
-			count++
-			v := tree.Predicate(forest.Data[i])
-			e += v[forest.Labels[i]]
-		}
+	for _, row := range tree.oobRows {
+		i := row - forest.evicted
+		v := tree.Predicate(forest.Data[i])
+		e += v[forest.Labels[i]]
 	}
-	tree.Validation = e / float64(count)
-	return tree
+	if len(tree.oobRows) > 0 {
+		tree.Validation = e / float64(len(tree.oobRows))
+	}
+	return tree, nil
 }
 
 func (forest *MongoClassForest[F, L]) BuildTree() *ClassificationTree[F, L] {
-
-	cursor, err := getData(*forest.database.Collection(fmt.Sprintf("steps_%s", forest.Game)), forest.NSize)
+	tree, err := forest.TryBuildTree()
 	if err != nil {
 		panic(err)
 	}
+	return tree
+}
+
+func (forest *MongoClassForest[F, L]) TryBuildTree() (*ClassificationTree[F, L], error) {
+	return forest.buildTree(context.Background(), newRand())
+}
+
+func (forest *MongoClassForest[F, L]) buildTree(ctx context.Context, rng *rand.Rand) (*ClassificationTree[F, L], error) {
+	collection := forest.database.Collection(fmt.Sprintf("steps_%s", forest.Game))
+	cursor, err := getData(ctx, *collection, forest.NSize)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
 	samples := make([][]F, 0, forest.NSize)
 	samples_labels := make([]L, 0, forest.NSize)
-	for cursor.Next(context.Background()) {
+	for cursor.Next(ctx) {
 		var data ClassificationDTO[F, L]
-		err := cursor.Decode(&data)
-		if err != nil {
-			panic(err)
+		if err := cursor.Decode(&data); err != nil {
+			return nil, err
+		}
+		if len(data.Input) == 0 {
+			return nil, ErrEmptyInput
 		}
 		samples = append(samples, data.Input[:len(data.Input)-1])
 		samples_labels = append(samples_labels, data.Label)
 	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, ErrEmptyInput
+	}
+	if err := validateInputs(samples, len(samples_labels), 0); err != nil {
+		return nil, err
+	}
+	if err := forest.validateSchema(len(samples[0])); err != nil {
+		return nil, err
+	}
+	if err := forest.validateBins(); err != nil {
+		return nil, err
+	}
 	tree := &ClassificationTree[F, L]{}
-	tree.Root = buildNode(samples, samples_labels, forest.MFeatures, forest.MaxDepth)
+	cfg := forest.growConfig(ctx, rng, min(forest.MFeatures, len(samples[0])))
+	if forest.MaxBins > 0 {
+		cfg.bins = computeBins(samples, forest.MaxBins, forest.Schema, forest.Missing)
+	}
+	tree.Root = buildNode(cfg, forest.criterion(), samples, allRows(len(samples)), samples_labels, forest.MaxDepth)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	count := 0
 	e := 0.0
-	cursor, err = getData(*forest.database.Collection(fmt.Sprintf("steps_%s", forest.Game)), forest.NSize/10)
+	validation, err := getData(ctx, *collection, forest.NSize/10)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	for cursor.Next(context.Background()) {
+	defer validation.Close(ctx)
+	for validation.Next(ctx) {
 		var data ClassificationDTO[F, L]
-		cursor.Decode(&data)
+		if err := validation.Decode(&data); err != nil {
+			return nil, err
+		}
+		if len(data.Input)-1 != len(samples[0]) {
+			return nil, fmt.Errorf("%w: validation row has %d features, want %d", ErrRaggedFeatures, len(data.Input)-1, len(samples[0]))
+		}
 		count++
 		v := tree.Predicate(data.Input[:len(data.Input)-1])
 		e += v[data.Label]
 	}
-	tree.Validation = math.Abs(e / float64(count))
-	return tree
+	if err := validation.Err(); err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		tree.Validation = math.Abs(e / float64(count))
+	}
+	return tree, nil
 }
 
 func (self *ClassificationForest[F, L]) Predicate(input []F) L {
@@ -331,42 +473,73 @@ func (forest *ClassificationForest[F, L]) WeightedPredicateWithData(input []F) m
 }
 
 func (forest *ClassificationForest[F, L]) DumpForest(fileName string) {
-	out_f, err := os.OpenFile(fileName, os.O_CREATE|os.O_RDWR, 0777)
-	if err != nil {
-		panic("failed to create " + fileName)
+	if err := forest.Dump(fileName); err != nil {
+		panic(err)
 	}
-	defer out_f.Close()
-	encoder := json.NewEncoder(out_f)
-	encoder.Encode(forest)
+}
+
+func (forest *ClassificationForest[F, L]) Dump(fileName string) error {
+	return writeFileAtomic(fileName, forest.Save)
 }
 
 func LoadForest[T Feature, L Label](fileName string) *ClassificationForest[T, L] {
+	forest, err := ReadForest[T, L](fileName)
+	if err != nil {
+		panic(err)
+	}
+	return forest
+}
+
+func ReadForest[T Feature, L Label](fileName string) (*ClassificationForest[T, L], error) {
 	in_f, err := os.Open(fileName)
 	if err != nil {
-		panic("failed to open " + fileName)
+		return nil, err
 	}
 	defer in_f.Close()
-	decoder := json.NewDecoder(in_f)
 	forest := &ClassificationForest[T, L]{}
-	decoder.Decode(forest)
-	return forest
+	if err := forest.Load(in_f); err != nil {
+		return nil, err
+	}
+	return forest, nil
 }
 
 func (forest *MongoClassForest[F, L]) DumpForest() {
+	if err := forest.Dump(); err != nil {
+		panic(err)
+	}
+}
+
+func (forest *MongoClassForest[F, L]) Dump() error {
 	upsert := true
 	_, err := forest.database.Collection("class_forests").ReplaceOne(context.Background(), bson.D{{Key: "game", Value: forest.Game}}, forest, &options.ReplaceOptions{Upsert: &upsert})
+	return err
+}
+
+func LoadMongoClassForest[F Feature, L Label](database *mongo.Database, game string) *MongoClassForest[F, L] {
+	forest, err := ReadMongoClassForest[F, L](database, game)
 	if err != nil {
 		panic(err)
 	}
+	return forest
 }
 
-func LoadMongoClassForest[F Feature, L Label](database *mongo.Database, game string) *MongoClassForest[F, L] {
+func ReadMongoClassForest[F Feature, L Label](database *mongo.Database, game string) (*MongoClassForest[F, L], error) {
 	result := database.Collection("class_forests").FindOne(context.Background(), bson.D{{Key: "game", Value: game}})
 	if result.Err() != nil {
-		panic(result.Err())
+		return nil, result.Err()
 	}
 	var forest MongoClassForest[F, L]
-	result.Decode(&forest)
+	if err := result.Decode(&forest); err != nil {
+		return nil, corruptModel(err)
+	}
+	raw, err := result.Raw()
+	if err != nil {
+		return nil, err
+	}
+	// Forests dumped before schemas existed carry no column types.
+	if _, err := raw.LookupErr("baseforest", "schema"); err != nil {
+		inferClassificationTypes(forest.Trees)
+	}
 	forest.database = database
-	return &forest
+	return &forest, nil
 }
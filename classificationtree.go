@@ -1,35 +1,99 @@
 package randomForest
 
 import (
-	"math"
+	"cmp"
+	"context"
 	"math/rand"
-	"reflect"
+	"slices"
 )
 
+// ColumnType selects how a column is split: CAT tests equality with one or
+// more categories, NUMERIC tests "<= value". AUTO picks CAT or NUMERIC from
+// the Go type of the feature, as forests did before schemas existed.
 type ColumnType int
 
 const (
 	CAT ColumnType = iota
 	NUMERIC
+	AUTO
 )
 
 type ClassificationTree[F Feature, L Label] struct {
 	Root       *ClassificationNode[F, L]
 	Validation float64
+	oobRows    []int
 }
 
 type ClassificationNode[F Feature, L Label] struct {
-	Size    int
-	Value   *F
-	Left    *ClassificationNode[F, L]
-	Right   *ClassificationNode[F, L]
-	Column  int
-	Labels  map[L]float64
-	Measure float64
+	Size        int
+	Value       *F
+	Categories  []F `json:",omitempty" bson:",omitempty"`
+	Left        *ClassificationNode[F, L]
+	Right       *ClassificationNode[F, L]
+	Column      int
+	Type        ColumnType
+	Missing     *F             `json:",omitempty" bson:",omitempty"`
+	MissingLeft bool           `json:",omitempty" bson:",omitempty"`
+	Surrogates  []Surrogate[F] `json:",omitempty" bson:",omitempty"`
+	Gain        float64        `json:",omitempty" bson:",omitempty"`
+	Labels      map[L]float64
+	Measure     float64
 }
 
-func getRandomRange(N int, M int) []int {
-	return rand.Perm(N)[:M]
+type split[F Feature] struct {
+	gain        float64
+	value       F
+	categories  []F
+	totalL      int
+	totalR      int
+	missing     *F
+	missingLeft bool
+}
+
+// gainTolerance is the difference below which two gains tie, so rounding
+// noise neither decides between splits nor splits labels that are constant.
+const gainTolerance = 1e-12
+
+// beats reports whether gain beats best by more than gainTolerance. Candidates
+// are visited in ascending order, so on a tie the lowest value stays.
+func beats(gain, best float64) bool {
+	return gain > best+gainTolerance
+}
+
+// beatsColumn is beats for the columns of a node, which are drawn in random
+// order: on a tie the lowest column wins. bestColumn is -1 before any column
+// has won.
+func beatsColumn(gain float64, c int, best float64, bestColumn int) bool {
+	return beats(gain, best) || (bestColumn >= 0 && c < bestColumn && !beats(best, gain))
+}
+
+func (s split[F]) goesLeft(column_type ColumnType, value F) bool {
+	if isMissing(value, s.missing) {
+		return s.missingLeft
+	}
+	if column_type == NUMERIC {
+		return value <= s.value
+	}
+	_, ok := slices.BinarySearch(s.categories, value)
+	return ok
+}
+
+type growConfig[F Feature] struct {
+	ctx           context.Context
+	rng           *rand.Rand
+	mFeatures     int
+	schema        []ColumnType
+	bins          *binning[F]
+	missing       []MissingMarker[F]
+	maxSurrogates int
+}
+
+func (cfg *growConfig[F]) columnType(c int, value F) ColumnType {
+	return resolveColumnType(cfg.schema, c, value)
+}
+
+func getRandomRange(rng *rand.Rand, N int, M int) []int {
+	return rng.Perm(N)[:M]
 }
 
 func getSamples[F Feature](ary [][]F, index []int) [][]F {
@@ -48,114 +112,100 @@ func getLabels[L any](ary []L, index []int) []L {
 	return result
 }
 
-func getEntropy[L Label](ep_map map[L]float64, total int) float64 {
-
-	for k := range ep_map {
-		ep_map[k] = ep_map[k] / float64(total) //normalize
+func getBestGain[F Feature, L Label](criterion SplitCriterion[L], samples [][]F, c int, samples_labels []L, column_type ColumnType, current_entropy float64, bins *binnedColumn[F]) split[F] {
+	if column_type == NUMERIC && bins != nil {
+		return getBestHistogramGain(criterion, samples_labels, current_entropy, bins)
 	}
-
-	entropy := 0.0
-	for _, v := range ep_map {
-		entropy += v * math.Log(1.0/v)
+	if column_type == NUMERIC {
+		return getBestNumericGain(criterion, samples, c, samples_labels, current_entropy)
 	}
 
-	return entropy
+	return getBestCategoricalGain(criterion, samples, c, samples_labels, current_entropy)
 }
 
-func getBestGain[F Feature, L Label](samples [][]F, c int, samples_labels []L, column_type ColumnType, current_entropy float64) (float64, F, int, int) {
+// getBestNumericGain sorts the column once and sweeps the label counts from
+// left to right, evaluating every "<= value" threshold in a single pass.
+func getBestNumericGain[F Feature, L Label](criterion SplitCriterion[L], samples [][]F, c int, samples_labels []L, current_entropy float64) split[F] {
 	var best_value F
 	best_gain := 0.0
 	best_total_r := 0
 	best_total_l := 0
 
-	uniq_values := make(map[F]int)
-	for i := 0; i < len(samples); i++ {
-		uniq_values[samples[i][c]] = 1
-	}
-
-	for value := range uniq_values {
-		map_l := make(map[L]float64)
-		map_r := make(map[L]float64)
-		total_l := 0
-		total_r := 0
-		if column_type == CAT {
-			for j := 0; j < len(samples); j++ {
-				if samples[j][c] == value {
-					total_l += 1
-					map_l[samples_labels[j]] += 1.0
-				} else {
-					total_r += 1
-					map_r[samples_labels[j]] += 1.0
-				}
-			}
+	order := sortedOrder(samples, c)
+	map_l := make(map[L]float64)
+	map_r := make(map[L]float64)
+	for _, l := range samples_labels {
+		map_r[l] += 1.0
+	}
+
+	for k, j := range order {
+		l := samples_labels[j]
+		map_l[l] += 1.0
+		map_r[l] -= 1.0
+		if map_r[l] == 0 {
+			delete(map_r, l)
 		}
-		if column_type == NUMERIC {
-			for j := 0; j < len(samples); j++ {
-				if samples[j][c] <= value {
-					total_l += 1
-					map_l[samples_labels[j]] += 1.0
-				} else {
-					total_r += 1
-					map_r[samples_labels[j]] += 1.0
-				}
-			}
+		if k+1 < len(order) && samples[order[k+1]][c] == samples[j][c] {
+			continue
 		}
+		total_l := k + 1
+		total_r := len(samples) - total_l
 
 		p1 := float64(total_r) / float64(len(samples))
 		p2 := float64(total_l) / float64(len(samples))
 
-		new_entropy := p1*getEntropy(map_r, total_r) + p2*getEntropy(map_l, total_l)
-		//fmt.Println(new_entropy,current_entropy)
+		new_entropy := p1*criterion.Impurity(map_r, total_r) + p2*criterion.Impurity(map_l, total_l)
 		entropy_gain := current_entropy - new_entropy
 
-		if entropy_gain >= best_gain {
+		if beats(entropy_gain, best_gain) {
 			best_gain = entropy_gain
-			best_value = value
+			best_value = samples[j][c]
 			best_total_l = total_l
 			best_total_r = total_r
 		}
 	}
 
-	return best_gain, best_value, best_total_l, best_total_r
+	return split[F]{gain: best_gain, value: best_value, totalL: best_total_l, totalR: best_total_r}
 }
 
-func splitSamples[F Feature](samples [][]F, column_type ColumnType, c int, value F) (partL []int, partR []int) {
+func sortedOrder[F Feature](samples [][]F, c int) []int {
+	order := make([]int, len(samples))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return cmp.Compare(samples[a][c], samples[b][c])
+	})
+	return order
+}
+
+func splitSamples[F Feature](samples [][]F, column_type ColumnType, c int, s split[F]) (partL []int, partR []int) {
 	partL = make([]int, 0, len(samples))
 	partR = make([]int, 0, len(samples))
-	if column_type == CAT {
-		for j := 0; j < len(samples); j++ {
-			if samples[j][c] == value {
-				partL = append(partL, j)
-			} else {
-				partR = append(partR, j)
-			}
-		}
-	}
-	if column_type == NUMERIC {
-		for j := 0; j < len(samples); j++ {
-			if samples[j][c] <= value {
-				partL = append(partL, j)
-			} else {
-				partR = append(partR, j)
-			}
+	for j := 0; j < len(samples); j++ {
+		if s.goesLeft(column_type, samples[j][c]) {
+			partL = append(partL, j)
+		} else {
+			partR = append(partR, j)
 		}
 	}
 
 	return partL, partR
 }
 
-func buildNode[F Feature, L Label](samples [][]F, labels []L, selectedFeatureCount int, depth int) *ClassificationNode[F, L] {
+// buildNode grows the subtree of samples; rows are their indices in the
+// binned data.
+func buildNode[F Feature, L Label](cfg *growConfig[F], criterion SplitCriterion[L], samples [][]F, rows []int, labels []L, depth int) *ClassificationNode[F, L] {
+	if cfg.ctx.Err() != nil {
+		return nil
+	}
 	column_count := len(samples[0])
 	//split_count := int(math.Log(float64(column_count)))
-	split_count := selectedFeatureCount
-	columns_choosen := getRandomRange(column_count, split_count)
-
-	best_gain := 0.0
+	split_count := cfg.mFeatures
+	columns_choosen := getRandomRange(cfg.rng, column_count, split_count)
 
-	var best_value F
-	var best_column int
-	var best_total_l int = 0
-	var best_total_r int = 0
+	var best split[F]
+	best_column := -1
 	var best_column_type ColumnType
 
 	current_entropy_map := make(map[L]float64)
@@ -163,44 +213,50 @@ func buildNode[F Feature, L Label](samples [][]F, labels []L, selectedFeatureCou
 		current_entropy_map[labels[i]] += 1
 	}
 
-	current_entropy := getEntropy(current_entropy_map, len(labels))
+	current_entropy := criterion.Impurity(current_entropy_map, len(labels))
 
 	for _, c := range columns_choosen {
-		column_type := CAT
-
-		if reflect.TypeOf(samples[0][c]) == reflect.TypeFor[float64]() {
-			column_type = NUMERIC
+		if cfg.ctx.Err() != nil {
+			return nil
 		}
+		column_type := cfg.columnType(c, samples[0][c])
 
-		gain, value, total_l, total_r := getBestGain(samples, c, labels, column_type, current_entropy)
-		//fmt.Println("kkkkk",gain,part_l,part_r)
-		if gain >= best_gain {
-			best_gain = gain
-			best_total_l = total_l
-			best_total_r = total_r
-			best_value = value
+		s := getBestGainWithMissing(cfg, criterion, samples, rows, c, labels, column_type, current_entropy)
+		if beatsColumn(s.gain, c, best.gain, best_column) {
+			best = s
 			best_column = c
 			best_column_type = column_type
 		}
 	}
 
-	if best_gain > 0 && best_total_l > 0 && best_total_r > 0 && depth > 0 {
+	if best.gain > 0 && best.totalL > 0 && best.totalR > 0 && depth > 0 {
 		node := &ClassificationNode[F, L]{
 			Size:    len(labels),
-			Measure: current_entropy + best_gain,
+			Measure: current_entropy + best.gain,
+			Gain:    best.gain,
+		}
+		if best_column_type == NUMERIC {
+			node.Value = &best.value
+		} else {
+			node.Categories = best.categories
 		}
-		node.Value = &best_value
 		node.Column = best_column
-		bestPartL, bestPartR := splitSamples(samples, best_column_type, best_column, best_value)
-		node.Left = buildNode(getSamples(samples, bestPartL), getLabels(labels, bestPartL), selectedFeatureCount, depth-1)
-		node.Right = buildNode(getSamples(samples, bestPartR), getLabels(labels, bestPartR), selectedFeatureCount, depth-1)
+		node.Type = best_column_type
+		node.Missing = best.missing
+		node.MissingLeft = best.missingLeft
+		if cfg.maxSurrogates > 0 {
+			node.Surrogates = findSurrogates(cfg, samples, best_column, best_column_type, best)
+		}
+		bestPartL, bestPartR := splitSamples(samples, best_column_type, best_column, best)
+		node.Left = buildNode(cfg, criterion, getSamples(samples, bestPartL), getLabels(rows, bestPartL), getLabels(labels, bestPartL), depth-1)
+		node.Right = buildNode(cfg, criterion, getSamples(samples, bestPartR), getLabels(rows, bestPartR), getLabels(labels, bestPartR), depth-1)
 		return node
 	}
 
-	return genLeafNode[F, L](labels)
+	return genLeafNode[F](criterion, labels)
 }
 
-func genLeafNode[F Feature, L Label](labels []L) *ClassificationNode[F, L] {
+func genLeafNode[F Feature, L Label](criterion SplitCriterion[L], labels []L) *ClassificationNode[F, L] {
 	counter := make(map[L]int)
 	e_map := make(map[L]float64)
 	for _, v := range labels {
@@ -210,7 +266,7 @@ func genLeafNode[F Feature, L Label](labels []L) *ClassificationNode[F, L] {
 
 	node := &ClassificationNode[F, L]{
 		Size:    len(labels),
-		Measure: getEntropy(e_map, len(labels)),
+		Measure: criterion.Impurity(e_map, len(labels)),
 	}
 	node.Labels = make(map[L]float64)
 	for l, v := range counter {
@@ -221,35 +277,144 @@ func genLeafNode[F Feature, L Label](labels []L) *ClassificationNode[F, L] {
 }
 
 func (node *ClassificationNode[F, L]) predicate(input []F) map[L]float64 {
-	if node.Value == nil { //leaf node
+	if node.isLeaf() {
 		return node.Labels
 	}
 
-	c := node.Column
-	value := input[c]
+	if node.goesLeft(input) && node.Left != nil {
+		return node.Left.predicate(input)
+	} else if node.Right != nil {
+		return node.Right.predicate(input)
+	}
+
+	return nil
+}
+
+func (node *ClassificationNode[F, L]) isLeaf() bool {
+	return node.Value == nil && node.Categories == nil
+}
+
+// goesLeft tests the input against the split: "<=" for numeric columns,
+// membership in Categories for categorical ones, so a category not seen in
+// training goes right. Categorical nodes written before subset splits
+// existed only hold a single Value.
+func (node *ClassificationNode[F, L]) goesLeft(input []F) bool {
+	value := input[node.Column]
+	if isMissing(value, node.Missing) {
+		return missingGoesLeft(node.Surrogates, input, node.MissingLeft)
+	}
+	return testLeft(node.columnType(value), node.Value, node.Categories, value)
+}
+
+// children returns the child the input follows first and the other one
+// second.
+func (node *ClassificationNode[F, L]) children(input []F) (*ClassificationNode[F, L], *ClassificationNode[F, L]) {
+	if node.goesLeft(input) {
+		return node.Left, node.Right
+	}
+	return node.Right, node.Left
+}
 
-	switch reflect.TypeOf(value) {
-	case reflect.TypeFor[float64]():
-		if value <= *node.Value && node.Left != nil {
-			return node.Left.predicate(input)
-		} else if node.Right != nil {
-			return node.Right.predicate(input)
+// expectation returns the leaf distributions averaged by the share of rows
+// reaching each leaf, the prediction expected without knowing the input.
+func (node *ClassificationNode[F, L]) expectation() map[L]float64 {
+	return node.expectations(nil)
+}
+
+// expectations computes the expectation of node bottom up and, unless cache
+// is nil, stores that of every node below it on the way.
+func (node *ClassificationNode[F, L]) expectations(cache map[*ClassificationNode[F, L]]map[L]float64) map[L]float64 {
+	if node == nil {
+		return nil
+	}
+	var result map[L]float64
+	if node.isLeaf() {
+		result = node.distribution()
+	} else {
+		result = make(map[L]float64)
+		left, right := coverShares(float64(node.Left.Size), float64(node.Right.Size))
+		for l, v := range node.Left.expectations(cache) {
+			result[l] += left * v
 		}
-	default:
-		if value == *node.Value && node.Left != nil {
-			return node.Left.predicate(input)
-		} else if node.Right != nil {
-			return node.Right.predicate(input)
+		for l, v := range node.Right.expectations(cache) {
+			result[l] += right * v
 		}
 	}
+	if cache != nil {
+		cache[node] = result
+	}
+	return result
+}
 
-	return nil
+// distribution returns the labels of a leaf summing to 1, as
+// PredicateWithData counts them; imported leaves may hold raw counts.
+func (node *ClassificationNode[F, L]) distribution() map[L]float64 {
+	total := 0.0
+	for _, v := range node.Labels {
+		total += v
+	}
+	result := make(map[L]float64, len(node.Labels))
+	for l, v := range node.Labels {
+		result[l] = v / total
+	}
+	return result
+}
+
+func (node *ClassificationNode[F, L]) columnType(value F) ColumnType {
+	if node.Type == AUTO {
+		return inferColumnType(value)
+	}
+	return node.Type
+}
+
+// inferTypes sets the column types of splits decoded from dumps that predate
+// them, which split on the Go type of the feature.
+func (node *ClassificationNode[F, L]) inferTypes() {
+	if node == nil || node.isLeaf() {
+		return
+	}
+	if node.Value != nil {
+		node.Type = inferColumnType(*node.Value)
+	}
+	node.Left.inferTypes()
+	node.Right.inferTypes()
 }
 
 func (tree *ClassificationTree[F, L]) Predicate(input []F) map[L]float64 {
 	return tree.Root.predicate(input)
 }
 
+func (tree *ClassificationTree[F, L]) Nodes() int {
+	return tree.Root.nodes()
+}
+
+func (tree *ClassificationTree[F, L]) oob() float64 {
+	return tree.Validation
+}
+
+func (node *ClassificationNode[F, L]) nodes() int {
+	if node == nil {
+		return 0
+	}
+	return 1 + node.Left.nodes() + node.Right.nodes()
+}
+
+// mdi returns the impurity decrease per feature, normalized to sum to 1.
+func (tree ClassificationTree[F, L]) mdi(nFeatures int) []float64 {
+	imp := make([]float64, nFeatures)
+	tree.Root.decrease(imp)
+	sum := 0.0
+	for _, v := range imp {
+		sum += v
+	}
+	if sum > 0 {
+		for i := range imp {
+			imp[i] /= sum
+		}
+	}
+	return imp
+}
+
 func (tree ClassificationTree[F, L]) importance(nFeatures int) []float64 {
 	imp := make([]float64, nFeatures)
 	tree.Root.importance(imp)
@@ -266,6 +431,17 @@ func (tree ClassificationTree[F, L]) importance(nFeatures int) []float64 {
 	return imp
 }
 
+// decrease adds the weighted impurity decrease of every split below node to
+// the column it splits on. Leaves contribute nothing.
+func (node *ClassificationNode[F, L]) decrease(imp []float64) {
+	if node == nil || node.isLeaf() {
+		return
+	}
+	imp[node.Column] += float64(node.Size) * node.Gain
+	node.Left.decrease(imp)
+	node.Right.decrease(imp)
+}
+
 func (node ClassificationNode[F, L]) importance(imp []float64) {
 	imp[node.Column] += float64(node.Size) * node.Measure
 	if node.Left != nil {
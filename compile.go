@@ -0,0 +1,321 @@
+package randomForest
+
+import "slices"
+
+const (
+	flagNumeric uint8 = 1 << iota
+	flagMarker
+	flagMissingLeft
+)
+
+// CompiledNode is a node of a CompiledTree. Leaves have Column -1 and keep
+// the offset of their value in Left. Categorical splits send a row left if
+// its value is in Categories[CatLo:CatHi].
+type CompiledNode[F Feature] struct {
+	Column    int32
+	Flags     uint8
+	Left      int32
+	Right     int32
+	CatLo     int32
+	CatHi     int32
+	Threshold F
+	Missing   F
+}
+
+// CompiledTree is a tree flattened into arrays for fast inference. The root
+// is Nodes[0].
+type CompiledTree[F Feature] struct {
+	Nodes      []CompiledNode[F]
+	Categories []F
+	Values     []float64
+	Surrogates map[int32][]Surrogate[F]
+}
+
+// CompiledClassifier is the inference form of a classification forest. Leaf
+// distributions are dense vectors indexed like Classes.
+type CompiledClassifier[F Feature, L Label] struct {
+	Classes     []L
+	Trees       []CompiledTree[F]
+	Calibration map[L]*Calibrator
+}
+
+// CompiledRegressor is the inference form of a regression forest.
+type CompiledRegressor[F Feature] struct {
+	Trees []CompiledTree[F]
+}
+
+// leaf returns the offset of the leaf value reached by input.
+func (tree *CompiledTree[F]) leaf(input []F) int32 {
+	nodes := tree.Nodes
+	k := int32(0)
+	for {
+		node := &nodes[k]
+		if node.Column < 0 {
+			return node.Left
+		}
+		value := input[node.Column]
+		var left bool
+		if value != value || (node.Flags&flagMarker != 0 && value == node.Missing) {
+			left = node.Flags&flagMissingLeft != 0
+			if surrogates, ok := tree.Surrogates[k]; ok {
+				left = missingGoesLeft(surrogates, input, left)
+			}
+		} else if node.Flags&flagNumeric != 0 {
+			left = value <= node.Threshold
+		} else {
+			_, left = slices.BinarySearch(tree.Categories[node.CatLo:node.CatHi], value)
+		}
+		if left {
+			k = node.Left
+		} else {
+			k = node.Right
+		}
+	}
+}
+
+// compileNode appends node and its subtree in pre-order and returns its
+// index. value appends the leaf value and returns its offset.
+func compileNode[F Feature, N any](tree *CompiledTree[F], node N, view func(N) (split *CompiledNode[F], left, right N, surrogates []Surrogate[F], categories []F, leaf bool), value func(N) int32) int32 {
+	k := int32(len(tree.Nodes))
+	tree.Nodes = append(tree.Nodes, CompiledNode[F]{})
+	split, left, right, surrogates, categories, leaf := view(node)
+	if leaf {
+		tree.Nodes[k] = CompiledNode[F]{Column: -1, Left: value(node)}
+		return k
+	}
+	split.CatLo = int32(len(tree.Categories))
+	tree.Categories = append(tree.Categories, categories...)
+	split.CatHi = int32(len(tree.Categories))
+	if len(surrogates) > 0 {
+		if tree.Surrogates == nil {
+			tree.Surrogates = make(map[int32][]Surrogate[F])
+		}
+		tree.Surrogates[k] = surrogates
+	}
+	split.Left = compileNode(tree, left, view, value)
+	split.Right = compileNode(tree, right, view, value)
+	tree.Nodes[k] = *split
+	return k
+}
+
+// compiledSplit converts the split fields shared by both node types.
+func compiledSplit[F Feature](column int, column_type ColumnType, value *F, categories []F, missing *F, missingLeft bool) (*CompiledNode[F], []F) {
+	split := &CompiledNode[F]{Column: int32(column)}
+	if column_type == AUTO && value != nil {
+		column_type = inferColumnType(*value)
+	}
+	if column_type == NUMERIC {
+		split.Flags |= flagNumeric
+		split.Threshold = *value
+	} else if categories == nil {
+		categories = []F{*value}
+	}
+	if missing != nil {
+		split.Flags |= flagMarker
+		split.Missing = *missing
+	}
+	if missingLeft {
+		split.Flags |= flagMissingLeft
+	}
+	return split, categories
+}
+
+func compileClassificationTree[F Feature, L Label](root *ClassificationNode[F, L], index map[L]int) CompiledTree[F] {
+	tree := CompiledTree[F]{}
+	view := func(node *ClassificationNode[F, L]) (*CompiledNode[F], *ClassificationNode[F, L], *ClassificationNode[F, L], []Surrogate[F], []F, bool) {
+		if node.isLeaf() || node.Left == nil || node.Right == nil {
+			return nil, nil, nil, nil, nil, true
+		}
+		split, categories := compiledSplit(node.Column, node.Type, node.Value, node.Categories, node.Missing, node.MissingLeft)
+		return split, node.Left, node.Right, node.Surrogates, categories, false
+	}
+	value := func(node *ClassificationNode[F, L]) int32 {
+		offset := int32(len(tree.Values))
+		tree.Values = append(tree.Values, make([]float64, len(index))...)
+		labels := node.leafLabels()
+		total := 0.0
+		for _, v := range labels {
+			total += v
+		}
+		for l, v := range labels {
+			tree.Values[int(offset)+index[l]] = v / total
+		}
+		return offset
+	}
+	compileNode(&tree, root, view, value)
+	return tree
+}
+
+func compileRegressionTree[F Feature](root *RegressionNode[F]) CompiledTree[F] {
+	tree := CompiledTree[F]{}
+	view := func(node *RegressionNode[F]) (*CompiledNode[F], *RegressionNode[F], *RegressionNode[F], []Surrogate[F], []F, bool) {
+		if node.isLeaf() || node.Left == nil || node.Right == nil {
+			return nil, nil, nil, nil, nil, true
+		}
+		split, categories := compiledSplit(node.Column, node.Type, node.Value, node.Categories, node.Missing, node.MissingLeft)
+		return split, node.Left, node.Right, node.Surrogates, categories, false
+	}
+	value := func(node *RegressionNode[F]) int32 {
+		tree.Values = append(tree.Values, node.leafLabel())
+		return int32(len(tree.Values) - 1)
+	}
+	compileNode(&tree, root, view, value)
+	return tree
+}
+
+// leafLabels returns what the pointer walk predicts at node: its own
+// distribution for leaves, or the one of the only child of a split node
+// missing the other.
+func (node *ClassificationNode[F, L]) leafLabels() map[L]float64 {
+	if node.isLeaf() {
+		return node.Labels
+	}
+	if node.Left != nil {
+		return node.Left.leafLabels()
+	}
+	if node.Right != nil {
+		return node.Right.leafLabels()
+	}
+	return nil
+}
+
+func (node *RegressionNode[F]) leafLabel() float64 {
+	if node.isLeaf() {
+		return node.Label
+	}
+	if node.Left != nil {
+		return node.Left.leafLabel()
+	}
+	if node.Right != nil {
+		return node.Right.leafLabel()
+	}
+	return 0
+}
+
+func compileClassifier[F Feature, L Label](trees []*ClassificationTree[F, L], calibration map[L]*Calibrator) *CompiledClassifier[F, L] {
+	classes := make(map[L]bool)
+	for _, tree := range trees {
+		tree.Root.collectLabels(classes)
+	}
+	c := &CompiledClassifier[F, L]{Classes: sortedKeys(classes), Trees: make([]CompiledTree[F], len(trees)), Calibration: calibration}
+	index := make(map[L]int, len(c.Classes))
+	for k, l := range c.Classes {
+		index[l] = k
+	}
+	for i, tree := range trees {
+		c.Trees[i] = compileClassificationTree(tree.Root, index)
+	}
+	return c
+}
+
+func (node *ClassificationNode[F, L]) collectLabels(classes map[L]bool) {
+	if node == nil {
+		return
+	}
+	for l := range node.Labels {
+		classes[l] = true
+	}
+	node.Left.collectLabels(classes)
+	node.Right.collectLabels(classes)
+}
+
+// Compile flattens the forest for fast inference.
+func (forest *ClassificationForest[F, L]) Compile() *CompiledClassifier[F, L] {
+	return compileClassifier(forest.Trees, forest.Calibration)
+}
+
+// Compile flattens the forest for fast inference.
+func (forest *MongoClassForest[F, L]) Compile() *CompiledClassifier[F, L] {
+	return compileClassifier(forest.Trees, nil)
+}
+
+// Compile flattens the forest for fast inference.
+func (forest *RegressionForest[F]) Compile() *CompiledRegressor[F] {
+	return compileRegressor(forest.Trees)
+}
+
+// Compile flattens the forest for fast inference.
+func (forest *MongoForest[F]) Compile() *CompiledRegressor[F] {
+	return compileRegressor(forest.Trees)
+}
+
+func compileRegressor[F Feature](trees []*RegressionTree[F]) *CompiledRegressor[F] {
+	c := &CompiledRegressor[F]{Trees: make([]CompiledTree[F], len(trees))}
+	for i, tree := range trees {
+		c.Trees[i] = compileRegressionTree(tree.Root)
+	}
+	return c
+}
+
+// Scores adds the leaf distributions of all trees into scores, indexed like
+// Classes, and returns it. It allocates only if scores is too short.
+func (c *CompiledClassifier[F, L]) Scores(input []F, scores []float64) []float64 {
+	if len(scores) < len(c.Classes) {
+		scores = make([]float64, len(c.Classes))
+	}
+	scores = scores[:len(c.Classes)]
+	clear(scores)
+	for t := range c.Trees {
+		tree := &c.Trees[t]
+		offset := tree.leaf(input)
+		for k, v := range tree.Values[offset : int(offset)+len(c.Classes)] {
+			scores[k] += v
+		}
+	}
+	return scores
+}
+
+// Predicate returns the class with the highest score, the first in Classes
+// on ties.
+func (c *CompiledClassifier[F, L]) Predicate(input []F) L {
+	var best L
+	scores := c.Scores(input, nil)
+	max := 0.0
+	for k, v := range scores {
+		if v > max {
+			max = v
+			best = c.Classes[k]
+		}
+	}
+	return best
+}
+
+// PredicateWithData returns the summed votes like
+// ClassificationForest.PredicateWithData.
+func (c *CompiledClassifier[F, L]) PredicateWithData(input []F) map[L]float64 {
+	result := make(map[L]float64)
+	for k, v := range c.Scores(input, nil) {
+		if v > 0 {
+			result[c.Classes[k]] = v
+		}
+	}
+	return result
+}
+
+// PredictProba returns the calibrated class probabilities like
+// ClassificationForest.PredictProba.
+func (c *CompiledClassifier[F, L]) PredictProba(input []F) map[L]float64 {
+	return calibrate(probabilities(c.PredicateWithData(input)), c.Calibration)
+}
+
+// PredictBatchInto predicts every input in parallel into out, which must
+// have the length of inputs.
+func (c *CompiledClassifier[F, L]) PredictBatchInto(inputs [][]F, out []L) error {
+	return predictBatch(inputs, out, c.Predicate)
+}
+
+// Predicate returns the mean prediction of the trees.
+func (c *CompiledRegressor[F]) Predicate(input []F) float64 {
+	total := 0.0
+	for t := range c.Trees {
+		tree := &c.Trees[t]
+		total += tree.Values[tree.leaf(input)]
+	}
+	return total / float64(len(c.Trees))
+}
+
+// PredictBatchInto predicts every input in parallel into out, which must
+// have the length of inputs.
+func (c *CompiledRegressor[F]) PredictBatchInto(inputs [][]F, out []float64) error {
+	return predictBatch(inputs, out, c.Predicate)
+}
@@ -0,0 +1,141 @@
+package randomForest
+
+import (
+	"math"
+	"testing"
+)
+
+func trainedIris(t testing.TB, x [][]float64, y []string) *ClassificationForest[float64, string] {
+	forest := NewClassificationForest[float64, string](1000, 50, 1, 0.5)
+	forest.MaxDepth = 10
+	forest.MaxSurrogates = 2
+	forest.Seed = 1
+	if err := forest.TryTrain(x, y, 50); err != nil {
+		t.Fatal(err)
+	}
+	return forest
+}
+
+func trainedSin(t testing.TB) (*RegressionForest[float64], [][]float64) {
+	x, y := sinData()
+	forest := NewRegressionForest[float64](1000, 50, 1, 1)
+	forest.Seed = 1
+	if err := forest.TryTrain(x, y, 50); err != nil {
+		t.Fatal(err)
+	}
+	inputs := make([][]float64, 2000)
+	for i := range inputs {
+		inputs[i] = []float64{float64(i) / 400.0}
+	}
+	return forest, inputs
+}
+
+// compareClassifier checks that the compiled forest votes like the pointer
+// walk, up to rounding, and picks its class wherever the vote is not tied.
+func compareClassifier[F Feature, L Label](t *testing.T, forest *ClassificationForest[F, L], inputs [][]F) {
+	t.Helper()
+	compiled := forest.Compile()
+	for i, input := range inputs {
+		want := forest.PredicateWithData(input)
+		if got := compiled.PredicateWithData(input); !sameVotes(got, want) {
+			t.Fatalf("row %d: compiled votes %v, pointer walk %v", i, got, want)
+		}
+		if _, tied := maxTied(want); !tied {
+			if got, want := compiled.Predicate(input), forest.Predicate(input); got != want {
+				t.Fatalf("row %d: compiled predicts %v, pointer walk %v", i, got, want)
+			}
+		}
+	}
+}
+
+func sameVotes[L Label](a, b map[L]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for l, v := range a {
+		if w, ok := b[l]; !ok || math.Abs(v-w) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+// maxTied returns the highest vote and whether another vote comes within
+// rounding of it.
+func maxTied[L Label](votes map[L]float64) (float64, bool) {
+	best := 0.0
+	for _, v := range votes {
+		best = max(best, v)
+	}
+	n := 0
+	for _, v := range votes {
+		if best-v < 1e-9 {
+			n++
+		}
+	}
+	return best, n > 1
+}
+
+func TestCompiledClassifierIris(t *testing.T) {
+	x, y := loadIris(t)
+	compareClassifier(t, trainedIris(t, x, y), x)
+}
+
+func TestCompiledClassifierIrisMissing(t *testing.T) {
+	x, y := loadIris(t)
+	x = blank(x, 0.2, math.NaN())
+	compareClassifier(t, trainedIris(t, x, y), x)
+}
+
+func TestCompiledClassifierCars(t *testing.T) {
+	x, y := loadCars(t)
+	forest := NewClassificationForest[string, string](2000, 20, 1, 0.5)
+	forest.MaxDepth = 10
+	forest.Seed = 1
+	if err := forest.TryTrain(x, y, 20); err != nil {
+		t.Fatal(err)
+	}
+	compareClassifier(t, forest, x)
+}
+
+func TestCompiledRegressorSin(t *testing.T) {
+	forest, inputs := trainedSin(t)
+	compiled := forest.Compile()
+	for i, input := range inputs {
+		if got, want := compiled.Predicate(input), forest.Predicate(input); got != want {
+			t.Fatalf("row %d: compiled predicts %v, pointer walk %v", i, got, want)
+		}
+	}
+}
+
+func BenchmarkPredictIris(b *testing.B) {
+	x, y := loadIris(b)
+	forest := trainedIris(b, x, y)
+	compiled := forest.Compile()
+	b.Run("pointer", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			forest.PredicateWithData(x[i%len(x)])
+		}
+	})
+	b.Run("compiled", func(b *testing.B) {
+		scores := make([]float64, len(compiled.Classes))
+		for i := 0; i < b.N; i++ {
+			compiled.Scores(x[i%len(x)], scores)
+		}
+	})
+}
+
+func BenchmarkPredictSin(b *testing.B) {
+	forest, inputs := trainedSin(b)
+	compiled := forest.Compile()
+	b.Run("pointer", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			forest.Predicate(inputs[i%len(inputs)])
+		}
+	})
+	b.Run("compiled", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			compiled.Predicate(inputs[i%len(inputs)])
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package randomForest
+
+import "math"
+
+// SplitCriterion measures the impurity of a node from its label counts.
+// Classification trees pick the split with the largest impurity decrease.
+type SplitCriterion[L Label] interface {
+	Impurity(counts map[L]float64, total int) float64
+}
+
+type CriterionFunc[L Label] func(counts map[L]float64, total int) float64
+
+func (f CriterionFunc[L]) Impurity(counts map[L]float64, total int) float64 {
+	return f(counts, total)
+}
+
+type Entropy[L Label] struct{}
+
+func (Entropy[L]) Impurity(counts map[L]float64, total int) float64 {
+	entropy := 0.0
+	for _, k := range sortedKeys(counts) {
+		v := counts[k] / float64(total)
+		if v > 0 {
+			entropy += v * math.Log(1.0/v)
+		}
+	}
+	return entropy
+}
+
+type Gini[L Label] struct{}
+
+func (Gini[L]) Impurity(counts map[L]float64, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	gini := 1.0
+	for _, k := range sortedKeys(counts) {
+		v := counts[k] / float64(total)
+		gini -= v * v
+	}
+	return gini
+}
+
+type MisclassificationError[L Label] struct{}
+
+func (MisclassificationError[L]) Impurity(counts map[L]float64, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	maxCount := 0.0
+	for _, v := range counts {
+		maxCount = max(maxCount, v)
+	}
+	return 1 - maxCount/float64(total)
+}
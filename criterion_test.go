@@ -0,0 +1,106 @@
+package randomForest
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCriterionImpurity(t *testing.T) {
+	golden := []struct {
+		counts                           map[string]float64
+		total                            int
+		entropy, gini, misclassification float64
+	}{
+		{map[string]float64{"a": 2, "b": 2}, 4, math.Ln2, 0.5, 0.5},
+		{map[string]float64{"a": 3, "b": 1}, 4, -(0.75*math.Log(0.75) + 0.25*math.Log(0.25)), 0.375, 0.25},
+		{map[string]float64{"a": 1, "b": 1, "c": 1}, 3, math.Log(3), 2.0 / 3, 2.0 / 3},
+		{map[string]float64{"a": 4}, 4, 0, 0, 0},
+		{map[string]float64{}, 0, 0, 0, 0},
+	}
+	for _, g := range golden {
+		for _, c := range []struct {
+			criterion SplitCriterion[string]
+			want      float64
+		}{
+			{Entropy[string]{}, g.entropy},
+			{Gini[string]{}, g.gini},
+			{MisclassificationError[string]{}, g.misclassification},
+		} {
+			if got := c.criterion.Impurity(g.counts, g.total); math.Abs(got-c.want) > 1e-12 {
+				t.Fatalf("%T of %v: got %v, want %v", c.criterion, g.counts, got, c.want)
+			}
+		}
+	}
+	double := CriterionFunc[string](func(counts map[string]float64, total int) float64 {
+		return 2 * Gini[string]{}.Impurity(counts, total)
+	})
+	if got := double.Impurity(map[string]float64{"a": 3, "b": 1}, 4); got != 0.75 {
+		t.Fatalf("CriterionFunc: got %v, want 0.75", got)
+	}
+}
+
+// criterionData has two 0/1 columns over 40 a and 40 b. Column 0 splits them
+// into (30 a, 10 b) and (10 a, 30 b), column 1 into (20 a, 40 b) and a pure
+// (20 a). Misclassification scores both 0.25; Gini prefers the pure side of
+// column 1 with 1/3 against 3/8, while the square of the misclassification
+// error prefers column 0 with 1/16 against 1/12.
+func criterionData() ([][]float64, []string) {
+	var x [][]float64
+	var y []string
+	for i := 0; i < 40; i++ {
+		x = append(x, []float64{float64(min(i/30, 1)), float64(i / 20)})
+		y = append(y, "a")
+		x = append(x, []float64{float64(min(i/10, 1)), 0})
+		y = append(y, "b")
+	}
+	return x, y
+}
+
+func rootColumn(t *testing.T, criterion SplitCriterion[string]) int {
+	x, y := criterionData()
+	cfg := &growConfig[float64]{ctx: context.Background(), rng: rand.New(rand.NewSource(1)), mFeatures: 2}
+	root := buildNode(cfg, criterion, x, allRows(len(x)), y, 1)
+	if root.isLeaf() {
+		t.Fatalf("%T did not split", criterion)
+	}
+	return root.Column
+}
+
+func TestCriterionChoosesSplit(t *testing.T) {
+	if c := rootColumn(t, Gini[string]{}); c != 1 {
+		t.Fatalf("Gini splits column %d, want 1", c)
+	}
+	if c := rootColumn(t, Entropy[string]{}); c != 1 {
+		t.Fatalf("Entropy splits column %d, want 1", c)
+	}
+	squared := CriterionFunc[string](func(counts map[string]float64, total int) float64 {
+		e := MisclassificationError[string]{}.Impurity(counts, total)
+		return e * e
+	})
+	if c := rootColumn(t, squared); c != 0 {
+		t.Fatalf("squared misclassification splits column %d, want 0", c)
+	}
+}
+
+func TestForestUsesCriterion(t *testing.T) {
+	x, y := loadIris(t)
+	calls := 0
+	forest := NewClassificationForest[float64, string](1000, 2, 1, 0.5)
+	forest.MaxDepth = 10
+	forest.Criterion = CriterionFunc[string](func(counts map[string]float64, total int) float64 {
+		calls++
+		return Gini[string]{}.Impurity(counts, total)
+	})
+	withCPUs(t, 1)
+	if err := forest.TryTrain(x, y, 2); err != nil {
+		t.Fatal(err)
+	}
+	if calls == 0 {
+		t.Fatal("the forest never called its Criterion")
+	}
+	if acc := accuracy(forest, x, y); acc < 0.9 {
+		t.Fatalf("accuracy %.3f with Gini as a CriterionFunc", acc)
+	}
+}
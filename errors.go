@@ -0,0 +1,41 @@
+package randomForest
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrEmptyInput     = errors.New("randomForest: empty input")
+	ErrRaggedFeatures = errors.New("randomForest: feature rows differ in length")
+	ErrLabelMismatch  = errors.New("randomForest: label count does not match input count")
+	ErrCorruptModel   = errors.New("randomForest: corrupt model")
+	ErrSchemaMismatch = errors.New("randomForest: row does not match schema")
+	ErrNoOOB          = errors.New("randomForest: no out-of-bag rows")
+	ErrOutputSize     = errors.New("randomForest: output count does not match input count")
+	ErrNotExportable  = errors.New("randomForest: forest cannot be exported")
+	ErrUnknownMethod  = errors.New("randomForest: unknown calibration method")
+	ErrMaxBins        = errors.New("randomForest: MaxBins out of range")
+)
+
+func validateInputs[F Feature](inputs [][]F, labelCount int, features int) error {
+	if len(inputs) == 0 || len(inputs[0]) == 0 {
+		return ErrEmptyInput
+	}
+	if len(inputs) != labelCount {
+		return fmt.Errorf("%w: %d inputs, %d labels", ErrLabelMismatch, len(inputs), labelCount)
+	}
+	if features == 0 {
+		features = len(inputs[0])
+	}
+	for i, row := range inputs {
+		if len(row) != features {
+			return fmt.Errorf("%w: row %d has %d features, want %d", ErrRaggedFeatures, i, len(row), features)
+		}
+	}
+	return nil
+}
+
+func corruptModel(err error) error {
+	return fmt.Errorf("%w: %w", ErrCorruptModel, err)
+}
@@ -0,0 +1,111 @@
+package randomForest
+
+// Step is one node on the path of an input through a tree. Prediction is
+// what the tree would predict if it stopped at this node: the class
+// distribution for classification, the mean label for regression.
+type Step[F Feature, V any] struct {
+	Leaf       bool
+	Column     int
+	Type       ColumnType
+	Value      *F
+	Categories []F
+	Missing    bool
+	Left       bool
+	Size       int
+	Prediction V
+}
+
+// Explanation lists the path of an input through every tree together with
+// the Saabas attribution of the prediction: Bias plus the sum of
+// Contributions over the features equals the forest output.
+type Explanation[F Feature, V any] struct {
+	Paths         [][]Step[F, V]
+	Bias          V
+	Contributions []V
+}
+
+// Explain walks input through every tree. Each split credits the change of
+// the node distribution from parent to child to the split column, so the
+// contributions add up to PredicateWithData(input).
+func (forest *ClassificationForest[F, L]) Explain(input []F) *Explanation[F, map[L]float64] {
+	e := &Explanation[F, map[L]float64]{
+		Bias:          make(map[L]float64),
+		Contributions: make([]map[L]float64, len(input)),
+	}
+	for c := range e.Contributions {
+		e.Contributions[c] = make(map[L]float64)
+	}
+	for _, tree := range forest.Trees {
+		expected := make(map[*ClassificationNode[F, L]]map[L]float64)
+		tree.Root.expectations(expected)
+		var path []Step[F, map[L]float64]
+		for node := tree.Root; node != nil; {
+			step := Step[F, map[L]float64]{Leaf: node.isLeaf(), Size: node.Size, Prediction: expected[node]}
+			if len(path) == 0 {
+				for l, v := range step.Prediction {
+					e.Bias[l] += v
+				}
+			} else {
+				parent := path[len(path)-1]
+				for l := range union(parent.Prediction, step.Prediction) {
+					e.Contributions[parent.Column][l] += step.Prediction[l] - parent.Prediction[l]
+				}
+			}
+			var next *ClassificationNode[F, L]
+			if !step.Leaf {
+				step.Column, step.Type, step.Value, step.Categories = node.Column, node.Type, node.Value, node.Categories
+				step.Missing = isMissing(input[node.Column], node.Missing)
+				next, _ = node.children(input)
+				step.Left = next == node.Left
+			}
+			path = append(path, step)
+			node = next
+		}
+		e.Paths = append(e.Paths, path)
+	}
+	return e
+}
+
+// Explain walks input through every tree. Each split credits the change of
+// the node mean from parent to child to the split column, so the
+// contributions add up to Predicate(input).
+func (forest *RegressionForest[F]) Explain(input []F) *Explanation[F, float64] {
+	e := &Explanation[F, float64]{Contributions: make([]float64, len(input))}
+	n := float64(len(forest.Trees))
+	for _, tree := range forest.Trees {
+		expected := make(map[*RegressionNode[F]]float64)
+		tree.Root.expectations(expected)
+		var path []Step[F, float64]
+		for node := tree.Root; node != nil; {
+			step := Step[F, float64]{Leaf: node.isLeaf(), Size: node.Size, Prediction: expected[node]}
+			if len(path) == 0 {
+				e.Bias += step.Prediction / n
+			} else {
+				parent := path[len(path)-1]
+				e.Contributions[parent.Column] += (step.Prediction - parent.Prediction) / n
+			}
+			var next *RegressionNode[F]
+			if !step.Leaf {
+				step.Column, step.Type, step.Value, step.Categories = node.Column, node.Type, node.Value, node.Categories
+				step.Missing = isMissing(input[node.Column], node.Missing)
+				next, _ = node.children(input)
+				step.Left = next == node.Left
+			}
+			path = append(path, step)
+			node = next
+		}
+		e.Paths = append(e.Paths, path)
+	}
+	return e
+}
+
+func union[L Label](a, b map[L]float64) map[L]bool {
+	keys := make(map[L]bool, len(a)+len(b))
+	for l := range a {
+		keys[l] = true
+	}
+	for l := range b {
+		keys[l] = true
+	}
+	return keys
+}
@@ -0,0 +1,42 @@
+package randomForest
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExplainSumsToPrediction(t *testing.T) {
+	x, y := loadIris(t)
+	classifier := trainedIris(t, x, y)
+	for i, input := range append(x, blank(x, 0.2, math.NaN())...) {
+		e := classifier.Explain(input)
+		got := make(map[string]float64)
+		for l, v := range e.Bias {
+			got[l] += v
+		}
+		for _, contribution := range e.Contributions {
+			for l, v := range contribution {
+				got[l] += v
+			}
+		}
+		for l, v := range got {
+			if math.Abs(v) < 1e-9 {
+				delete(got, l)
+			}
+		}
+		if want := classifier.PredicateWithData(input); !sameVotes(got, want) {
+			t.Fatalf("row %d: bias plus contributions %v, votes %v", i, got, want)
+		}
+		if len(e.Paths) != len(classifier.Trees) {
+			t.Fatalf("row %d: %d paths for %d trees", i, len(e.Paths), len(classifier.Trees))
+		}
+	}
+
+	regressor, inputs := trainedSin(t)
+	for i := 0; i < len(inputs); i += 10 {
+		e := regressor.Explain(inputs[i])
+		if got, want := e.Bias+sum(e.Contributions), regressor.Predicate(inputs[i]); math.Abs(got-want) > 1e-9 {
+			t.Fatalf("input %v: bias plus contributions %v, prediction %v", inputs[i], got, want)
+		}
+	}
+}
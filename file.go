@@ -0,0 +1,137 @@
+package randomForest
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// writeFileAtomic writes to a temporary file next to fileName and renames it
+// into place, so readers see either the old or the complete new file. The
+// file and then its directory are synced, so the rename survives a crash.
+func writeFileAtomic(fileName string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), "."+filepath.Base(fileName)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), fileName); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(fileName))
+}
+
+// syncDir flushes the entries of dir. Windows cannot sync directories, so
+// there a crash right after the rename may still bring back the old file.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// WriteTo implements io.WriterTo with the format of Save.
+func (forest *ClassificationForest[F, L]) WriteTo(w io.Writer) (int64, error) {
+	c := &countingWriter{w: w}
+	err := forest.Save(c)
+	return c.n, err
+}
+
+// ReadFrom implements io.ReaderFrom with the format of Load. It reads r to
+// the end.
+func (forest *ClassificationForest[F, L]) ReadFrom(r io.Reader) (int64, error) {
+	c := &countingReader{r: r}
+	err := forest.Load(c)
+	return c.n, err
+}
+
+// WriteTo implements io.WriterTo with the format of Save.
+func (forest *RegressionForest[F]) WriteTo(w io.Writer) (int64, error) {
+	c := &countingWriter{w: w}
+	err := forest.Save(c)
+	return c.n, err
+}
+
+// ReadFrom implements io.ReaderFrom with the format of Load. It reads r to
+// the end.
+func (forest *RegressionForest[F]) ReadFrom(r io.Reader) (int64, error) {
+	c := &countingReader{r: r}
+	err := forest.Load(c)
+	return c.n, err
+}
+
+// ReadForestFS reads a forest written by Dump or Save from fsys, e.g. an
+// embed.FS.
+func ReadForestFS[T Feature, L Label](fsys fs.FS, name string) (*ClassificationForest[T, L], error) {
+	in_f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in_f.Close()
+	forest := &ClassificationForest[T, L]{}
+	if err := forest.Load(in_f); err != nil {
+		return nil, err
+	}
+	return forest, nil
+}
+
+// ReadRegressionForestFS reads a forest written by Dump or Save from fsys,
+// e.g. an embed.FS.
+func ReadRegressionForestFS[F Feature](fsys fs.FS, name string) (*RegressionForest[F], error) {
+	in_f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in_f.Close()
+	forest := &RegressionForest[F]{}
+	if err := forest.Load(in_f); err != nil {
+		return nil, err
+	}
+	return forest, nil
+}
@@ -0,0 +1,140 @@
+package randomForest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWriteToReadFrom(t *testing.T) {
+	x, y := loadIris(t)
+	forest := trainedIris(t, x, y)
+	var buf bytes.Buffer
+	n, err := forest.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("WriteTo returned %d, wrote %d bytes", n, buf.Len())
+	}
+	size := buf.Len()
+	loaded := &ClassificationForest[float64, string]{}
+	if n, err = loaded.ReadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(size) {
+		t.Fatalf("ReadFrom returned %d, want %d", n, size)
+	}
+	for i, row := range x {
+		if got, want := loaded.PredicateWithData(row), forest.PredicateWithData(row); !sameVotes(got, want) {
+			t.Fatalf("row %d: got %v after loading, want %v", i, got, want)
+		}
+	}
+
+	regression, inputs := trainedSin(t)
+	buf.Reset()
+	if n, err = regression.WriteTo(&buf); err != nil || n != int64(buf.Len()) {
+		t.Fatalf("WriteTo returned %d, %v for %d bytes", n, err, buf.Len())
+	}
+	size = buf.Len()
+	loadedRegression := &RegressionForest[float64]{}
+	if n, err = loadedRegression.ReadFrom(&buf); err != nil || n != int64(size) {
+		t.Fatalf("ReadFrom returned %d, %v for %d bytes", n, err, size)
+	}
+	for i, input := range inputs {
+		if got, want := loadedRegression.Predicate(input), regression.Predicate(input); got != want {
+			t.Fatalf("row %d: got %v after loading, want %v", i, got, want)
+		}
+	}
+}
+
+func TestReadForestFS(t *testing.T) {
+	x, y := loadIris(t)
+	forest := trainedIris(t, x, y)
+	regression, inputs := trainedSin(t)
+	var classification, regressionDump bytes.Buffer
+	if err := forest.Save(&classification); err != nil {
+		t.Fatal(err)
+	}
+	if err := regression.Save(&regressionDump); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{
+		"models/iris.rf": {Data: classification.Bytes()},
+		"models/sin.rf":  {Data: regressionDump.Bytes()},
+	}
+	loaded, err := ReadForestFS[float64, string](fsys, "models/iris.rf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := loaded.PredicateWithData(x[0]), forest.PredicateWithData(x[0]); !sameVotes(got, want) {
+		t.Fatalf("got %v from the FS, want %v", got, want)
+	}
+	loadedRegression, err := ReadRegressionForestFS[float64](fsys, "models/sin.rf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := loadedRegression.Predicate(inputs[0]), regression.Predicate(inputs[0]); got != want {
+		t.Fatalf("got %v from the FS, want %v", got, want)
+	}
+	if _, err := ReadForestFS[float64, string](fsys, "models/missing.rf"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("got %v for a missing file, want %v", err, os.ErrNotExist)
+	}
+}
+
+// Dumping over a larger file must not leave its tail behind.
+func TestDumpReplacesLargerFile(t *testing.T) {
+	x, y := loadIris(t)
+	forest := trainedIris(t, x, y)
+	var want bytes.Buffer
+	if err := forest.Save(&want); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "iris.rf")
+	if err := os.WriteFile(name, bytes.Repeat([]byte{0xff}, 2*want.Len()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := forest.Dump(name); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Fatalf("file holds %d bytes, want the %d of Save", len(got), want.Len())
+	}
+	if _, err := ReadForest[float64, string](name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailedDumpKeepsOldFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "iris.rf")
+	if err := os.WriteFile(name, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	failure := errors.New("disk full")
+	err := writeFileAtomic(name, func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("got %v, want %v", err, failure)
+	}
+	if got, err := os.ReadFile(name); err != nil || string(got) != "old" {
+		t.Fatalf("file holds %q, %v after a failed write, want the old content", got, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("directory holds %d entries after a failed write, want only the old file", len(entries))
+	}
+}
@@ -0,0 +1,983 @@
+package randomForest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"reflect"
+)
+
+// The binary model format is
+//
+//	magic "RFGO" | major uint16 | minor uint16 | kind | feature type | label type
+//	sections, each a uvarint byte length followed by its content
+//	CRC-32 (IEEE) of everything before it, little endian
+//
+// A reader accepts any minor version of its major version: newer minor
+// versions may only append fields to the end of a section or append new
+// sections, which older readers skip. Another magic or major version fails
+// with ErrUnsupportedVersion, a checksum mismatch with ErrCorruptModel.
+// Files starting with '{' are dumps written by Dump before the binary format
+// existed and are decoded as JSON.
+const (
+	formatMagic = "RFGO"
+	formatMajor = 1
+	formatMinor = 2
+)
+
+const (
+	kindClassification uint8 = iota + 1
+	kindRegression
+	kindMongoClassification
+	kindMongoRegression
+)
+
+var ErrUnsupportedVersion = errors.New("randomForest: unsupported model format version")
+
+const (
+	nodeLeaf uint8 = 1 << iota
+	nodeValue
+	nodeCategories
+	nodeMissing
+	nodeMissingLeft
+	nodeNil
+	nodeReverse
+)
+
+// typeCode identifies the Go kind behind a Feature or Label type parameter.
+func typeCode[T Feature]() uint8 {
+	return uint8(reflect.TypeFor[T]().Kind())
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) byte(b uint8) {
+	e.buf = append(e.buf, b)
+}
+
+func (e *encoder) uvarint(v int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(v))
+}
+
+func (e *encoder) varint(v int64) {
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+func (e *encoder) float(v float64) {
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
+
+func (e *encoder) floats(v []float64) {
+	e.uvarint(len(v))
+	for _, x := range v {
+		e.float(x)
+	}
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(len(s))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) section(write func(e *encoder)) {
+	s := &encoder{}
+	write(s)
+	e.uvarint(len(s.buf))
+	e.buf = append(e.buf, s.buf...)
+}
+
+func putValue[T Feature](e *encoder, v T) {
+	r := reflect.ValueOf(v)
+	switch r.Kind() {
+	case reflect.String:
+		e.string(r.String())
+	case reflect.Float64:
+		e.float(r.Float())
+	default:
+		e.varint(r.Int())
+	}
+}
+
+func putValues[T Feature](e *encoder, v []T) {
+	e.uvarint(len(v))
+	for _, x := range v {
+		putValue(e, x)
+	}
+}
+
+// decoder reads what encoder wrote. The first error sticks and every later
+// read returns zero values.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.buf) {
+		d.fail(io.ErrUnexpectedEOF)
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) byte() uint8 {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uvarint() int {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 || v > math.MaxInt32 {
+		d.fail(io.ErrUnexpectedEOF)
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return int(v)
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.fail(io.ErrUnexpectedEOF)
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) float() float64 {
+	if b := d.next(8); b != nil {
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	}
+	return 0
+}
+
+// count reads a length and checks that at least min bytes per element are
+// left, so corrupt lengths cannot trigger huge allocations.
+func (d *decoder) count(min int) int {
+	n := d.uvarint()
+	if n*min > len(d.buf) {
+		d.fail(io.ErrUnexpectedEOF)
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) floats() []float64 {
+	v := make([]float64, d.count(8))
+	for i := range v {
+		v[i] = d.float()
+	}
+	return v
+}
+
+func (d *decoder) string() string {
+	return string(d.next(d.count(1)))
+}
+
+// section returns a decoder over the next section and skips it, including
+// fields appended by newer minor versions.
+func (d *decoder) section() *decoder {
+	buf := d.next(d.count(1))
+	return &decoder{buf: buf, err: d.err}
+}
+
+// more reports whether anything is left, which tells sections and fields
+// appended by a newer minor version from the end of an older file.
+func (d *decoder) more() bool {
+	return d.err == nil && len(d.buf) > 0
+}
+
+func (d *decoder) join(s *decoder) {
+	if s.err != nil {
+		d.fail(s.err)
+	}
+}
+
+func getValue[T Feature](d *decoder) T {
+	var v T
+	r := reflect.ValueOf(&v).Elem()
+	switch r.Kind() {
+	case reflect.String:
+		r.SetString(d.string())
+	case reflect.Float64:
+		r.SetFloat(d.float())
+	default:
+		r.SetInt(d.varint())
+	}
+	return v
+}
+
+func getValues[T Feature](d *decoder) []T {
+	v := make([]T, d.count(1))
+	for i := range v {
+		v[i] = getValue[T](d)
+	}
+	return v
+}
+
+func writeModel[F Feature](w io.Writer, kind, labelType uint8, sections ...func(e *encoder)) error {
+	e := &encoder{}
+	e.buf = append(e.buf, formatMagic...)
+	e.buf = binary.LittleEndian.AppendUint16(e.buf, formatMajor)
+	e.buf = binary.LittleEndian.AppendUint16(e.buf, formatMinor)
+	e.byte(kind)
+	e.byte(typeCode[F]())
+	e.byte(labelType)
+	for _, s := range sections {
+		e.section(s)
+	}
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, crc32.ChecksumIEEE(e.buf))
+	_, err := w.Write(e.buf)
+	return err
+}
+
+// readModel checks the envelope of a binary model and returns a decoder for
+// its sections, or nil together with the raw bytes of a legacy JSON dump.
+func readModel[F Feature](r io.Reader, kind, labelType uint8) (*decoder, []byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		return nil, data, nil
+	}
+	if !bytes.HasPrefix(data, []byte(formatMagic)) {
+		return nil, nil, fmt.Errorf("%w: not a model file", ErrUnsupportedVersion)
+	}
+	if len(data) < len(formatMagic)+11 {
+		return nil, nil, corruptModel(io.ErrUnexpectedEOF)
+	}
+	// A new major version may change everything after it, the checksum
+	// included.
+	if major := int(binary.LittleEndian.Uint16(data[len(formatMagic):])); major != formatMajor {
+		return nil, nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, major)
+	}
+	body, sum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, nil, corruptModel(errors.New("checksum mismatch"))
+	}
+	d := &decoder{buf: body[len(formatMagic)+4:]}
+	if k, f, l := d.byte(), d.byte(), d.byte(); k != kind || f != typeCode[F]() || l != labelType {
+		return nil, nil, corruptModel(fmt.Errorf("model kind %d with types %d/%d, want %d with %d/%d", k, f, l, kind, typeCode[F](), labelType))
+	}
+	return d, nil, nil
+}
+
+// keep copies the settings that Save does not write from old, the forest a
+// Load replaces.
+func (forest *BaseForest[F]) keep(old *BaseForest[F]) {
+	if old != nil {
+		forest.Progress = old.Progress
+	}
+}
+
+// loadJSON decodes a legacy JSON dump into forest.
+func loadJSON(data []byte, forest any) error {
+	if err := json.Unmarshal(data, forest); err != nil {
+		return corruptModel(err)
+	}
+	return nil
+}
+
+// inferClassificationTypes sets the column types of legacy trees, whose
+// splits were typed by the feature.
+func inferClassificationTypes[F Feature, L Label](trees []*ClassificationTree[F, L]) {
+	for _, tree := range trees {
+		if tree != nil {
+			tree.Root.inferTypes()
+		}
+	}
+}
+
+func inferRegressionTypes[F Feature](trees []*RegressionTree[F]) {
+	for _, tree := range trees {
+		if tree != nil {
+			tree.Root.inferTypes()
+		}
+	}
+}
+
+func (forest *BaseForest[F]) encode(e *encoder) {
+	e.uvarint(forest.Features)
+	e.uvarint(forest.MFeatures)
+	e.float(forest.MFeaturesFactor)
+	e.uvarint(forest.NSize)
+	e.float(forest.NSizeFactor)
+	e.uvarint(forest.BufferSize)
+	e.uvarint(forest.TreeLimit)
+	e.uvarint(forest.MaxDepth)
+	e.varint(forest.Seed)
+	e.uvarint(forest.MaxBins)
+	e.uvarint(forest.MaxSurrogates)
+	e.uvarint(len(forest.Schema))
+	for _, t := range forest.Schema {
+		e.uvarint(int(t))
+	}
+	e.uvarint(len(forest.Missing))
+	for _, m := range forest.Missing {
+		e.uvarint(m.Column)
+		putValue(e, m.Value)
+	}
+}
+
+func (forest *BaseForest[F]) decode(d *decoder) {
+	forest.Features = d.uvarint()
+	forest.MFeatures = d.uvarint()
+	forest.MFeaturesFactor = d.float()
+	forest.NSize = d.uvarint()
+	forest.NSizeFactor = d.float()
+	forest.BufferSize = d.uvarint()
+	forest.TreeLimit = d.uvarint()
+	forest.MaxDepth = d.uvarint()
+	forest.Seed = d.varint()
+	forest.MaxBins = d.uvarint()
+	forest.MaxSurrogates = d.uvarint()
+	forest.Schema = nil
+	if n := d.count(1); n > 0 {
+		forest.Schema = make([]ColumnType, n)
+		for i := range forest.Schema {
+			forest.Schema[i] = ColumnType(d.uvarint())
+		}
+	}
+	forest.Missing = nil
+	for n := d.count(2); n > 0; n-- {
+		forest.Missing = append(forest.Missing, MissingMarker[F]{Column: d.uvarint(), Value: getValue[F](d)})
+	}
+}
+
+// encode writes the encoder section added in minor version 1: a presence
+// byte, then the schema and the categories of every column.
+func (enc *RowEncoder) encode(e *encoder) {
+	if enc == nil {
+		e.byte(0)
+		return
+	}
+	e.byte(1)
+	e.uvarint(len(enc.Schema))
+	for c, t := range enc.Schema {
+		e.uvarint(int(t))
+		var categories []string
+		if c < len(enc.Categories) {
+			categories = enc.Categories[c]
+		}
+		e.uvarint(len(categories))
+		for _, name := range categories {
+			e.string(name)
+		}
+	}
+}
+
+func decodeRowEncoder(d *decoder) *RowEncoder {
+	if d.byte() == 0 {
+		return nil
+	}
+	n := d.count(2)
+	enc := NewRowEncoder(make([]ColumnType, n)...)
+	for c := range n {
+		enc.Schema[c] = ColumnType(d.uvarint())
+		for k := d.count(1); k > 0; k-- {
+			enc.code(c, d.string())
+		}
+	}
+	return enc
+}
+
+// encodeSplit writes the split fields shared by both node types.
+func encodeSplit[F Feature](e *encoder, flags uint8, column int, column_type ColumnType, value *F, categories []F, missing *F, surrogates []Surrogate[F], gain float64) {
+	if value != nil {
+		flags |= nodeValue
+	}
+	if categories != nil {
+		flags |= nodeCategories
+	}
+	if missing != nil {
+		flags |= nodeMissing
+	}
+	e.byte(flags)
+	e.uvarint(column)
+	e.uvarint(int(column_type))
+	e.float(gain)
+	if value != nil {
+		putValue(e, *value)
+	}
+	if categories != nil {
+		putValues(e, categories)
+	}
+	if missing != nil {
+		putValue(e, *missing)
+	}
+	e.uvarint(len(surrogates))
+	for _, s := range surrogates {
+		flags := uint8(0)
+		if s.Reverse {
+			flags |= nodeReverse
+		}
+		encodeSplit(e, flags, s.Column, s.Type, s.Value, s.Categories, s.Missing, nil, s.Agreement)
+	}
+}
+
+type decodedSplit[F Feature] struct {
+	flags      uint8
+	column     int
+	columnType ColumnType
+	gain       float64
+	value      *F
+	categories []F
+	missing    *F
+	surrogates []Surrogate[F]
+}
+
+func decodeSplit[F Feature](d *decoder, flags uint8) decodedSplit[F] {
+	s := decodedSplit[F]{flags: flags, column: d.uvarint(), columnType: ColumnType(d.uvarint()), gain: d.float()}
+	if flags&nodeValue != 0 {
+		v := getValue[F](d)
+		s.value = &v
+	}
+	if flags&nodeCategories != 0 {
+		s.categories = getValues[F](d)
+	}
+	if flags&nodeMissing != 0 {
+		v := getValue[F](d)
+		s.missing = &v
+	}
+	if n := d.count(3); n > 0 {
+		s.surrogates = make([]Surrogate[F], n)
+		for i := range s.surrogates {
+			sur := decodeSplit[F](d, d.byte())
+			s.surrogates[i] = Surrogate[F]{
+				Column:     sur.column,
+				Type:       sur.columnType,
+				Value:      sur.value,
+				Categories: sur.categories,
+				Missing:    sur.missing,
+				Reverse:    sur.flags&nodeReverse != 0,
+				Agreement:  sur.gain,
+			}
+		}
+	}
+	return s
+}
+
+// encodeClassificationNode writes node and its subtree in pre-order. Leaf
+// labels are written as indices into the label dictionary.
+func encodeClassificationNode[F Feature, L Label](e *encoder, node *ClassificationNode[F, L], index map[L]int) {
+	if node == nil {
+		e.byte(nodeNil)
+		return
+	}
+	if node.isLeaf() {
+		e.byte(nodeLeaf)
+		e.uvarint(node.Size)
+		e.float(node.Measure)
+		e.uvarint(len(node.Labels))
+		for _, l := range sortedKeys(node.Labels) {
+			e.uvarint(index[l])
+			e.float(node.Labels[l])
+		}
+		return
+	}
+	flags := uint8(0)
+	if node.MissingLeft {
+		flags |= nodeMissingLeft
+	}
+	encodeSplit(e, flags, node.Column, node.Type, node.Value, node.Categories, node.Missing, node.Surrogates, node.Gain)
+	e.uvarint(node.Size)
+	e.float(node.Measure)
+	encodeClassificationNode(e, node.Left, index)
+	encodeClassificationNode(e, node.Right, index)
+}
+
+func decodeClassificationNode[F Feature, L Label](d *decoder, labels []L, depth int) *ClassificationNode[F, L] {
+	flags := d.byte()
+	if d.err != nil || flags&nodeNil != 0 {
+		return nil
+	}
+	if depth > maxDecodeDepth {
+		d.fail(errors.New("tree too deep"))
+		return nil
+	}
+	if flags&nodeLeaf != 0 {
+		node := &ClassificationNode[F, L]{Size: d.uvarint(), Measure: d.float(), Labels: make(map[L]float64)}
+		for n := d.count(9); n > 0; n-- {
+			k := d.uvarint()
+			if k >= len(labels) {
+				d.fail(fmt.Errorf("label %d out of range", k))
+				return nil
+			}
+			node.Labels[labels[k]] = d.float()
+		}
+		return node
+	}
+	s := decodeSplit[F](d, flags)
+	node := &ClassificationNode[F, L]{
+		Column:      s.column,
+		Type:        s.columnType,
+		Gain:        s.gain,
+		Value:       s.value,
+		Categories:  s.categories,
+		Missing:     s.missing,
+		MissingLeft: flags&nodeMissingLeft != 0,
+		Surrogates:  s.surrogates,
+		Size:        d.uvarint(),
+		Measure:     d.float(),
+	}
+	node.Left = decodeClassificationNode[F](d, labels, depth+1)
+	node.Right = decodeClassificationNode[F](d, labels, depth+1)
+	return node
+}
+
+func encodeRegressionNode[F Feature](e *encoder, node *RegressionNode[F]) {
+	if node == nil {
+		e.byte(nodeNil)
+		return
+	}
+	if node.isLeaf() {
+		e.byte(nodeLeaf)
+		e.uvarint(node.Size)
+		e.float(node.Measure)
+		e.float(node.Label)
+		return
+	}
+	flags := uint8(0)
+	if node.MissingLeft {
+		flags |= nodeMissingLeft
+	}
+	encodeSplit(e, flags, node.Column, node.Type, node.Value, node.Categories, node.Missing, node.Surrogates, node.Gain)
+	e.uvarint(node.Size)
+	e.float(node.Measure)
+	encodeRegressionNode(e, node.Left)
+	encodeRegressionNode(e, node.Right)
+}
+
+func decodeRegressionNode[F Feature](d *decoder, depth int) *RegressionNode[F] {
+	flags := d.byte()
+	if d.err != nil || flags&nodeNil != 0 {
+		return nil
+	}
+	if depth > maxDecodeDepth {
+		d.fail(errors.New("tree too deep"))
+		return nil
+	}
+	if flags&nodeLeaf != 0 {
+		return &RegressionNode[F]{Size: d.uvarint(), Measure: d.float(), Label: d.float()}
+	}
+	s := decodeSplit[F](d, flags)
+	node := &RegressionNode[F]{
+		Column:      s.column,
+		Type:        s.columnType,
+		Gain:        s.gain,
+		Value:       s.value,
+		Categories:  s.categories,
+		Missing:     s.missing,
+		MissingLeft: flags&nodeMissingLeft != 0,
+		Surrogates:  s.surrogates,
+		Size:        d.uvarint(),
+		Measure:     d.float(),
+	}
+	node.Left = decodeRegressionNode[F](d, depth+1)
+	node.Right = decodeRegressionNode[F](d, depth+1)
+	return node
+}
+
+// maxDecodeDepth bounds the recursion on corrupt input.
+const maxDecodeDepth = 10000
+
+func encodeClassificationTrees[F Feature, L Label](e *encoder, trees []*ClassificationTree[F, L], index map[L]int) {
+	e.uvarint(len(trees))
+	for _, tree := range trees {
+		e.float(tree.Validation)
+		encodeClassificationNode(e, tree.Root, index)
+	}
+}
+
+func decodeClassificationTrees[F Feature, L Label](d *decoder, labels []L) []*ClassificationTree[F, L] {
+	trees := make([]*ClassificationTree[F, L], d.count(9))
+	for i := range trees {
+		trees[i] = &ClassificationTree[F, L]{Validation: d.float()}
+		trees[i].Root = decodeClassificationNode[F](d, labels, 0)
+	}
+	return trees
+}
+
+func encodeRegressionTrees[F Feature](e *encoder, trees []*RegressionTree[F]) {
+	e.uvarint(len(trees))
+	for _, tree := range trees {
+		e.float(tree.Validation)
+		encodeRegressionNode(e, tree.Root)
+	}
+}
+
+func decodeRegressionTrees[F Feature](d *decoder) []*RegressionTree[F] {
+	trees := make([]*RegressionTree[F], d.count(9))
+	for i := range trees {
+		trees[i] = &RegressionTree[F]{Validation: d.float()}
+		trees[i].Root = decodeRegressionNode[F](d, 0)
+	}
+	return trees
+}
+
+// encodeOOB writes the out-of-bag section added in minor version 2: the
+// number of rows evicted from the training buffer, then the out-of-bag rows
+// of every tree as ascending deltas.
+func encodeOOB(e *encoder, evicted int, bags [][]int) {
+	e.uvarint(evicted)
+	e.uvarint(len(bags))
+	for _, rows := range bags {
+		e.uvarint(len(rows))
+		prev := 0
+		for _, row := range rows {
+			e.uvarint(row - prev)
+			prev = row
+		}
+	}
+}
+
+func decodeOOB(d *decoder, trees int) (int, [][]int) {
+	evicted := d.uvarint()
+	bags := make([][]int, d.count(1))
+	if len(bags) != trees {
+		d.fail(fmt.Errorf("out-of-bag rows of %d trees, model has %d", len(bags), trees))
+		return 0, nil
+	}
+	for t := range bags {
+		n := d.count(1)
+		if n == 0 {
+			continue
+		}
+		bags[t] = make([]int, n)
+		prev := 0
+		for i := range bags[t] {
+			prev += d.uvarint()
+			bags[t][i] = prev
+		}
+	}
+	return evicted, bags
+}
+
+// labelDictionary returns the labels of all leaves, sorted, and their index.
+func labelDictionary[F Feature, L Label](trees []*ClassificationTree[F, L], calibration map[L]*Calibrator) ([]L, map[L]int) {
+	classes := make(map[L]bool)
+	for _, tree := range trees {
+		tree.Root.collectLabels(classes)
+	}
+	for l := range calibration {
+		classes[l] = true
+	}
+	labels := sortedKeys(classes)
+	index := make(map[L]int, len(labels))
+	for k, l := range labels {
+		index[l] = k
+	}
+	return labels, index
+}
+
+func encodeCalibration[L Label](e *encoder, calibration map[L]*Calibrator, index map[L]int) {
+	e.uvarint(len(calibration))
+	for _, l := range sortedKeys(calibration) {
+		c := calibration[l]
+		e.uvarint(index[l])
+		e.uvarint(int(c.Method))
+		e.float(c.A)
+		e.float(c.B)
+		e.floats(c.X)
+		e.floats(c.Y)
+	}
+}
+
+func decodeCalibration[L Label](d *decoder, labels []L) map[L]*Calibrator {
+	n := d.count(20)
+	if n == 0 {
+		return nil
+	}
+	calibration := make(map[L]*Calibrator, n)
+	for ; n > 0; n-- {
+		k := d.uvarint()
+		c := &Calibrator{Method: CalibrationMethod(d.uvarint()), A: d.float(), B: d.float(), X: d.floats(), Y: d.floats()}
+		if len(c.X) == 0 {
+			c.X, c.Y = nil, nil
+		}
+		if k >= len(labels) || len(c.X) != len(c.Y) {
+			d.fail(errors.New("bad calibration"))
+			return nil
+		}
+		calibration[labels[k]] = c
+	}
+	return calibration
+}
+
+// Save writes the forest in the versioned binary model format. The training
+// buffer is not saved, but the out-of-bag rows of every tree are: after Load,
+// set Data and Labels to the rows the buffer held to use the out-of-bag
+// scores and permutation importance.
+func (forest *ClassificationForest[F, L]) Save(w io.Writer) error {
+	labels, index := labelDictionary(forest.Trees, forest.Calibration)
+	return writeModel[F](w, kindClassification, typeCode[L](),
+		func(e *encoder) {
+			forest.BaseForest.encode(e)
+			e.uvarint(forest.Classes)
+		},
+		func(e *encoder) { putValues(e, labels) },
+		func(e *encoder) { encodeCalibration(e, forest.Calibration, index) },
+		func(e *encoder) { encodeClassificationTrees(e, forest.Trees, index) },
+		forest.Encoder.encode,
+		func(e *encoder) {
+			bags := make([][]int, len(forest.Trees))
+			for t, tree := range forest.Trees {
+				bags[t] = tree.oobRows
+			}
+			encodeOOB(e, forest.evicted, bags)
+		},
+	)
+}
+
+// Load replaces the forest with one read by Save, or from a legacy JSON
+// dump. Criterion and Progress, which are not saved, are kept; saved
+// settings such as MaxBins and MaxDepth come from the model.
+func (forest *ClassificationForest[F, L]) Load(r io.Reader) error {
+	d, legacy, err := readModel[F](r, kindClassification, typeCode[L]())
+	if err != nil {
+		return err
+	}
+	loaded := &ClassificationForest[F, L]{BaseForest: &BaseForest[F]{}}
+	if legacy != nil {
+		if err := loadJSON(legacy, loaded); err != nil {
+			return err
+		}
+		inferClassificationTypes(loaded.Trees)
+	} else {
+		s := d.section()
+		loaded.BaseForest.decode(s)
+		loaded.Classes = s.uvarint()
+		d.join(s)
+		s = d.section()
+		labels := getValues[L](s)
+		d.join(s)
+		s = d.section()
+		loaded.Calibration = decodeCalibration(s, labels)
+		d.join(s)
+		s = d.section()
+		loaded.Trees = decodeClassificationTrees[F](s, labels)
+		d.join(s)
+		if d.more() {
+			s = d.section()
+			loaded.Encoder = decodeRowEncoder(s)
+			d.join(s)
+		}
+		if d.more() {
+			s = d.section()
+			evicted, bags := decodeOOB(s, len(loaded.Trees))
+			loaded.evicted = evicted
+			for t, rows := range bags {
+				loaded.Trees[t].oobRows = rows
+			}
+			d.join(s)
+		}
+		if d.err != nil {
+			return corruptModel(d.err)
+		}
+	}
+	loaded.BaseForest.keep(forest.BaseForest)
+	loaded.Criterion = forest.Criterion
+	*forest = *loaded
+	return nil
+}
+
+// Save writes the forest in the versioned binary model format. The
+// database handle is not saved.
+func (forest *MongoClassForest[F, L]) Save(w io.Writer) error {
+	labels, index := labelDictionary(forest.Trees, nil)
+	return writeModel[F](w, kindMongoClassification, typeCode[L](),
+		func(e *encoder) {
+			forest.BaseForest.encode(e)
+			e.uvarint(forest.Classes)
+			e.string(forest.Game)
+		},
+		func(e *encoder) { putValues(e, labels) },
+		func(e *encoder) { encodeClassificationTrees(e, forest.Trees, index) },
+		forest.Encoder.encode,
+	)
+}
+
+// Load replaces the forest with one read by Save, keeping the database
+// handle, Criterion and Progress.
+func (forest *MongoClassForest[F, L]) Load(r io.Reader) error {
+	d, legacy, err := readModel[F](r, kindMongoClassification, typeCode[L]())
+	if err != nil {
+		return err
+	}
+	loaded := &MongoClassForest[F, L]{BaseForest: &BaseForest[F]{}, database: forest.database}
+	if legacy != nil {
+		if err := loadJSON(legacy, loaded); err != nil {
+			return err
+		}
+		inferClassificationTypes(loaded.Trees)
+	} else {
+		s := d.section()
+		loaded.BaseForest.decode(s)
+		loaded.Classes = s.uvarint()
+		loaded.Game = s.string()
+		d.join(s)
+		s = d.section()
+		labels := getValues[L](s)
+		d.join(s)
+		s = d.section()
+		loaded.Trees = decodeClassificationTrees[F](s, labels)
+		d.join(s)
+		if d.more() {
+			s = d.section()
+			loaded.Encoder = decodeRowEncoder(s)
+			d.join(s)
+		}
+		if d.err != nil {
+			return corruptModel(d.err)
+		}
+	}
+	loaded.BaseForest.keep(forest.BaseForest)
+	loaded.Criterion = forest.Criterion
+	*forest = *loaded
+	return nil
+}
+
+// Save writes the forest in the versioned binary model format. The training
+// buffer is not saved, but the out-of-bag rows of every tree are: after Load,
+// set Data and Labels to the rows the buffer held to use the out-of-bag
+// scores and permutation importance.
+func (forest *RegressionForest[F]) Save(w io.Writer) error {
+	return writeModel[F](w, kindRegression, 0,
+		func(e *encoder) {
+			forest.BaseForest.encode(e)
+			e.float(forest.Range)
+		},
+		func(e *encoder) { encodeRegressionTrees(e, forest.Trees) },
+		forest.Encoder.encode,
+		func(e *encoder) {
+			bags := make([][]int, len(forest.Trees))
+			for t, tree := range forest.Trees {
+				bags[t] = tree.oobRows
+			}
+			encodeOOB(e, forest.evicted, bags)
+		},
+	)
+}
+
+// Load replaces the forest with one read by Save, or from a legacy JSON
+// dump. Progress, which is not saved, is kept; saved settings such as
+// MaxBins and MaxDepth come from the model.
+func (forest *RegressionForest[F]) Load(r io.Reader) error {
+	d, legacy, err := readModel[F](r, kindRegression, 0)
+	if err != nil {
+		return err
+	}
+	loaded := &RegressionForest[F]{BaseForest: &BaseForest[F]{}}
+	if legacy != nil {
+		if err := loadJSON(legacy, loaded); err != nil {
+			return err
+		}
+		inferRegressionTypes(loaded.Trees)
+	} else {
+		s := d.section()
+		loaded.BaseForest.decode(s)
+		loaded.Range = s.float()
+		d.join(s)
+		s = d.section()
+		loaded.Trees = decodeRegressionTrees[F](s)
+		d.join(s)
+		if d.more() {
+			s = d.section()
+			loaded.Encoder = decodeRowEncoder(s)
+			d.join(s)
+		}
+		if d.more() {
+			s = d.section()
+			evicted, bags := decodeOOB(s, len(loaded.Trees))
+			loaded.evicted = evicted
+			for t, rows := range bags {
+				loaded.Trees[t].oobRows = rows
+			}
+			d.join(s)
+		}
+		if d.err != nil {
+			return corruptModel(d.err)
+		}
+	}
+	loaded.BaseForest.keep(forest.BaseForest)
+	*forest = *loaded
+	return nil
+}
+
+// Save writes the forest in the versioned binary model format. The
+// database handle is not saved.
+func (forest *MongoForest[F]) Save(w io.Writer) error {
+	return writeModel[F](w, kindMongoRegression, 0,
+		func(e *encoder) {
+			forest.BaseForest.encode(e)
+			e.float(forest.Range)
+			e.string(forest.Game)
+		},
+		func(e *encoder) { encodeRegressionTrees(e, forest.Trees) },
+		forest.Encoder.encode,
+	)
+}
+
+// Load replaces the forest with one read by Save, keeping the database
+// handle and Progress.
+func (forest *MongoForest[F]) Load(r io.Reader) error {
+	d, legacy, err := readModel[F](r, kindMongoRegression, 0)
+	if err != nil {
+		return err
+	}
+	loaded := &MongoForest[F]{BaseForest: &BaseForest[F]{}, database: forest.database}
+	if legacy != nil {
+		if err := loadJSON(legacy, loaded); err != nil {
+			return err
+		}
+		inferRegressionTypes(loaded.Trees)
+	} else {
+		s := d.section()
+		loaded.BaseForest.decode(s)
+		loaded.Range = s.float()
+		loaded.Game = s.string()
+		d.join(s)
+		s = d.section()
+		loaded.Trees = decodeRegressionTrees[F](s)
+		d.join(s)
+		if d.more() {
+			s = d.section()
+			loaded.Encoder = decodeRowEncoder(s)
+			d.join(s)
+		}
+		if d.err != nil {
+			return corruptModel(d.err)
+		}
+	}
+	loaded.BaseForest.keep(forest.BaseForest)
+	*forest = *loaded
+	return nil
+}
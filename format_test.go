@@ -0,0 +1,168 @@
+package randomForest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+func TestLoadKeepsUnsavedSettings(t *testing.T) {
+	x, y := loadIris(t)
+	forest := NewClassificationForest[float64, string](1000, 5, 1, 0.5)
+	forest.MaxDepth = 10
+	forest.MaxBins = 16
+	if err := forest.TryTrain(x, y, 5); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := forest.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	progress := NewChanProgress(make(chan ProgressEvent, 1))
+	loaded := NewClassificationForest[float64, string](10, 1, 1, 1)
+	loaded.Criterion = Gini[string]{}
+	loaded.Progress = progress
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.Criterion.(Gini[string]); !ok {
+		t.Fatalf("Criterion reset to %v", loaded.Criterion)
+	}
+	if loaded.Progress != ProgressReporter(progress) {
+		t.Fatalf("Progress reset to %v", loaded.Progress)
+	}
+	if loaded.MaxBins != 16 || loaded.MaxDepth != 10 || len(loaded.Trees) != 5 {
+		t.Fatalf("got MaxBins %d, MaxDepth %d and %d trees, want the saved 16, 10 and 5", loaded.MaxBins, loaded.MaxDepth, len(loaded.Trees))
+	}
+}
+
+func savedIris(t *testing.T) (*ClassificationForest[float64, string], []byte) {
+	x, y := loadIris(t)
+	forest := trainedIris(t, x, y)
+	var buf bytes.Buffer
+	if err := forest.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return forest, buf.Bytes()
+}
+
+// modelSections splits a saved model into its header and sections, without
+// the checksum.
+func modelSections(t *testing.T, data []byte) ([]byte, [][]byte) {
+	header := len(formatMagic) + 7
+	rest := data[header : len(data)-4]
+	var sections [][]byte
+	for len(rest) > 0 {
+		n, k := binary.Uvarint(rest)
+		if k <= 0 || int(n) > len(rest)-k {
+			t.Fatal("bad section length")
+		}
+		sections = append(sections, rest[k:k+int(n)])
+		rest = rest[k+int(n):]
+	}
+	return data[:header], sections
+}
+
+// buildModel writes header with the given minor version, the sections and a
+// fresh checksum.
+func buildModel(header []byte, minor uint16, sections ...[]byte) []byte {
+	data := append([]byte{}, header...)
+	binary.LittleEndian.PutUint16(data[len(formatMagic)+2:], minor)
+	for _, s := range sections {
+		data = binary.AppendUvarint(data, uint64(len(s)))
+		data = append(data, s...)
+	}
+	return binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+}
+
+func loadsLike(t *testing.T, forest *ClassificationForest[float64, string], data []byte) *ClassificationForest[float64, string] {
+	t.Helper()
+	loaded := &ClassificationForest[float64, string]{}
+	if err := loaded.Load(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	x, _ := loadIris(t)
+	for i, row := range x {
+		if got, want := loaded.PredicateWithData(row), forest.PredicateWithData(row); !sameVotes(got, want) {
+			t.Fatalf("row %d: got %v after loading, want %v", i, got, want)
+		}
+	}
+	return loaded
+}
+
+func TestLoadFlippedByte(t *testing.T) {
+	_, data := savedIris(t)
+	for _, k := range []int{len(formatMagic) + 5, len(data) / 2, len(data) - 1} {
+		corrupt := bytes.Clone(data)
+		corrupt[k] ^= 0x10
+		if err := (&ClassificationForest[float64, string]{}).Load(bytes.NewReader(corrupt)); !errors.Is(err, ErrCorruptModel) {
+			t.Fatalf("byte %d flipped: got %v, want %v", k, err, ErrCorruptModel)
+		}
+	}
+}
+
+func TestLoadUnsupportedVersion(t *testing.T) {
+	_, data := savedIris(t)
+	magic := bytes.Clone(data)
+	copy(magic, "RFG2")
+	header, sections := modelSections(t, data)
+	major := bytes.Clone(header)
+	binary.LittleEndian.PutUint16(major[len(formatMagic):], formatMajor+1)
+	for name, model := range map[string][]byte{"magic": magic, "major": buildModel(major, 0, sections...)} {
+		if err := (&ClassificationForest[float64, string]{}).Load(bytes.NewReader(model)); !errors.Is(err, ErrUnsupportedVersion) {
+			t.Fatalf("%s: got %v, want %v", name, err, ErrUnsupportedVersion)
+		}
+	}
+}
+
+// A newer minor version may append sections and fields, which are skipped.
+func TestLoadNewerMinor(t *testing.T) {
+	forest, data := savedIris(t)
+	header, sections := modelSections(t, data)
+	sections[0] = append(bytes.Clone(sections[0]), 1, 2, 3)
+	loadsLike(t, forest, buildModel(header, formatMinor+1, append(sections, []byte("future section"))...))
+}
+
+// Minor version 0 had neither the row encoder nor the out-of-bag section.
+func TestLoadMinorZero(t *testing.T) {
+	forest, data := savedIris(t)
+	header, sections := modelSections(t, data)
+	if len(sections) != 6 {
+		t.Fatalf("got %d sections, want 6", len(sections))
+	}
+	loaded := loadsLike(t, forest, buildModel(header, 0, sections[:4]...))
+	if loaded.Encoder != nil {
+		t.Fatalf("got encoder %v from a minor 0 file", loaded.Encoder)
+	}
+	loaded.Data, loaded.Labels = forest.Data, forest.Labels
+	if _, err := loaded.OOBScore(); !errors.Is(err, ErrNoOOB) {
+		t.Fatalf("got %v without out-of-bag rows, want %v", err, ErrNoOOB)
+	}
+}
+
+// Dump wrote the forest as JSON before the binary format existed.
+func TestLoadLegacyJSON(t *testing.T) {
+	forest, _ := savedIris(t)
+	data, err := json.Marshal(forest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadsLike(t, forest, data)
+
+	regression, inputs := trainedSin(t)
+	if data, err = json.Marshal(regression); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &RegressionForest[float64]{}
+	if err := loaded.Load(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	for i, input := range inputs {
+		if got, want := loaded.Predicate(input), regression.Predicate(input); got != want {
+			t.Fatalf("row %d: got %v after loading, want %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package randomForest
+
+import (
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func loadIris(t testing.TB) ([][]float64, []string) {
+	b, err := os.ReadFile("example_iris/iris2.data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var x [][]float64
+	var y []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		tup := strings.Split(line, ",")
+		row := []float64{}
+		for _, v := range tup[:len(tup)-1] {
+			f, _ := strconv.ParseFloat(v, 64)
+			row = append(row, f)
+		}
+		x = append(x, row)
+		y = append(y, tup[len(tup)-1])
+	}
+	return x, y
+}
+
+func loadCars(t testing.TB) ([][]string, []string) {
+	b, err := os.ReadFile("example_cars/car.data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var x [][]string
+	var y []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		tup := strings.Split(line, ",")
+		x = append(x, tup[:len(tup)-1])
+		y = append(y, tup[len(tup)-1])
+	}
+	return x, y
+}
+
+// syntheticDigits returns n rows of size pixels in [0, 1] drawn around ten
+// random class prototypes, a stand-in for MNIST of any size.
+func syntheticDigits(n, size int, seed int64) ([][]float64, []string) {
+	rng := rand.New(rand.NewSource(seed))
+	prototypes := make([][]float64, 10)
+	for k := range prototypes {
+		prototypes[k] = make([]float64, size)
+		for p := range prototypes[k] {
+			prototypes[k][p] = rng.Float64()
+		}
+	}
+	x := make([][]float64, n)
+	y := make([]string, n)
+	for i := range x {
+		k := rng.Intn(len(prototypes))
+		x[i] = make([]float64, size)
+		for p, v := range prototypes[k] {
+			x[i][p] = min(max(v+rng.NormFloat64()*0.3, 0), 1)
+		}
+		y[i] = strconv.Itoa(k)
+	}
+	return x, y
+}
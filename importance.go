@@ -0,0 +1,210 @@
+package randomForest
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// FeatureImportance holds the mean and standard deviation of a per-feature
+// score over repeated measurements.
+type FeatureImportance struct {
+	Mean []float64
+	Std  []float64
+}
+
+// permutedLoss returns the mean loss over rows before and after shuffling
+// column c among them.
+func permutedLoss[F Feature](data [][]F, rows []int, c int, rng *rand.Rand, loss func(row []F, i int) float64) (float64, float64) {
+	perm := rng.Perm(len(rows))
+	row := make([]F, len(data[rows[0]]))
+	base, permuted := 0.0, 0.0
+	for k, i := range rows {
+		base += loss(data[i], i)
+		copy(row, data[i])
+		row[c] = data[rows[perm[k]]][c]
+		permuted += loss(row, i)
+	}
+	return base / float64(len(rows)), permuted / float64(len(rows))
+}
+
+// permutationImportance calls increase repeats times for every column on
+// NUM_CPU workers, each call with its own seeded RNG.
+func permutationImportance(features, repeats int, seed int64, increase func(c int, rng *rand.Rand) float64) *FeatureImportance {
+	imp := &FeatureImportance{Mean: make([]float64, features), Std: make([]float64, features)}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(max(NUM_CPU, 1), features); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				sum, sq := 0.0, 0.0
+				for r := 0; r < repeats; r++ {
+					v := increase(c, treeRand(seed, r*features+c))
+					sum += v
+					sq += v * v
+				}
+				mean := sum / float64(repeats)
+				imp.Mean[c] = mean
+				imp.Std[c] = math.Sqrt(max(sq/float64(repeats)-mean*mean, 0))
+			}
+		}()
+	}
+	for c := 0; c < features; c++ {
+		jobs <- c
+	}
+	close(jobs)
+	wg.Wait()
+	return imp
+}
+
+// oobTreeRows returns the out-of-bag rows of every tree that are still in
+// the buffer.
+func (forest *BaseForest[F]) oobTreeRows(trees [][]int) [][]int {
+	result := make([][]int, len(trees))
+	for t, rows := range trees {
+		for _, row := range rows {
+			if i, ok := forest.oobIndex(row); ok {
+				result[t] = append(result[t], i)
+			}
+		}
+	}
+	return result
+}
+
+// PermutationImportance measures, for each feature, how much the accuracy of
+// every tree on its out-of-bag rows drops when that feature is shuffled among
+// them, averaged over trees and repeated repeats times.
+func (forest *ClassificationForest[F, L]) PermutationImportance(repeats int) (*FeatureImportance, error) {
+	bags := make([][]int, len(forest.Trees))
+	for t, tree := range forest.Trees {
+		bags[t] = tree.oobRows
+	}
+	bags = forest.oobTreeRows(bags)
+	if !hasRows(bags) {
+		return nil, ErrNoOOB
+	}
+	return permutationImportance(forest.Features, max(repeats, 1), forest.seed(), func(c int, rng *rand.Rand) float64 {
+		total, trees := 0.0, 0
+		for t, tree := range forest.Trees {
+			if len(bags[t]) == 0 {
+				continue
+			}
+			base, permuted := permutedLoss(forest.Data, bags[t], c, rng, func(row []F, i int) float64 {
+				return misclassified(tree.Predicate(row), forest.Labels[i])
+			})
+			total += permuted - base
+			trees++
+		}
+		return total / float64(trees)
+	}), nil
+}
+
+// PermutationImportanceOn measures the accuracy drop of the whole forest on a
+// validation set when each feature is shuffled.
+func (forest *ClassificationForest[F, L]) PermutationImportanceOn(inputs [][]F, labels []L, repeats int) (*FeatureImportance, error) {
+	if err := validateInputs(inputs, len(labels), forest.Features); err != nil {
+		return nil, err
+	}
+	rows := allRows(len(inputs))
+	return permutationImportance(forest.Features, max(repeats, 1), forest.seed(), func(c int, rng *rand.Rand) float64 {
+		base, permuted := permutedLoss(inputs, rows, c, rng, func(row []F, i int) float64 {
+			return misclassified(forest.PredicateWithData(row), labels[i])
+		})
+		return permuted - base
+	}), nil
+}
+
+// PermutationImportance measures, for each feature, how much the mean squared
+// error of every tree on its out-of-bag rows grows when that feature is
+// shuffled among them, averaged over trees and repeated repeats times.
+func (forest *RegressionForest[F]) PermutationImportance(repeats int) (*FeatureImportance, error) {
+	bags := make([][]int, len(forest.Trees))
+	for t, tree := range forest.Trees {
+		bags[t] = tree.oobRows
+	}
+	bags = forest.oobTreeRows(bags)
+	if !hasRows(bags) {
+		return nil, ErrNoOOB
+	}
+	return permutationImportance(forest.Features, max(repeats, 1), forest.seed(), func(c int, rng *rand.Rand) float64 {
+		total, trees := 0.0, 0
+		for t, tree := range forest.Trees {
+			if len(bags[t]) == 0 {
+				continue
+			}
+			base, permuted := permutedLoss(forest.Data, bags[t], c, rng, func(row []F, i int) float64 {
+				d := tree.Predicate(row) - forest.Labels[i]
+				return d * d
+			})
+			total += permuted - base
+			trees++
+		}
+		return total / float64(trees)
+	}), nil
+}
+
+// PermutationImportanceOn measures the growth of the forest's mean squared
+// error on a validation set when each feature is shuffled.
+func (forest *RegressionForest[F]) PermutationImportanceOn(inputs [][]F, labels []float64, repeats int) (*FeatureImportance, error) {
+	if err := validateInputs(inputs, len(labels), forest.Features); err != nil {
+		return nil, err
+	}
+	rows := allRows(len(inputs))
+	return permutationImportance(forest.Features, max(repeats, 1), forest.seed(), func(c int, rng *rand.Rand) float64 {
+		base, permuted := permutedLoss(inputs, rows, c, rng, func(row []F, i int) float64 {
+			d := forest.Predicate(row) - labels[i]
+			return d * d
+		})
+		return permuted - base
+	}), nil
+}
+
+func misclassified[L Label](votes map[L]float64, label L) float64 {
+	if l, _ := maxLabel(votes); l == label {
+		return 0
+	}
+	return 1
+}
+
+func hasRows(bags [][]int) bool {
+	for _, rows := range bags {
+		if len(rows) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func allRows(n int) []int {
+	rows := make([]int, n)
+	for i := range rows {
+		rows[i] = i
+	}
+	return rows
+}
+
+type impurityTree interface {
+	mdi(nFeatures int) []float64
+}
+
+// meanDecreaseImpurity returns the weighted impurity decrease of the splits
+// on each feature, normalized per tree and averaged over the forest, so it
+// sums to 1 when every tree splits. Unlike Importance it skips leaves, which
+// Importance charges to column 0. Trees trained before split gains were
+// stored contribute zeros.
+func meanDecreaseImpurity[T impurityTree](trees []T, features int) []float64 {
+	imp := make([]float64, features)
+	for _, tree := range trees {
+		for i, v := range tree.mdi(features) {
+			imp[i] += v
+		}
+	}
+	if len(trees) > 0 {
+		for i := range imp {
+			imp[i] /= float64(len(trees))
+		}
+	}
+	return imp
+}
@@ -0,0 +1,163 @@
+package randomForest
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// signalAndNoise returns rows whose label depends only on column 0; column 1
+// is uniform noise.
+func signalAndNoise(n int, seed int64) ([][]float64, []string, []float64) {
+	rng := rand.New(rand.NewSource(seed))
+	x := make([][]float64, n)
+	classes := make([]string, n)
+	values := make([]float64, n)
+	for i := range x {
+		x[i] = []float64{rng.Float64(), rng.Float64()}
+		classes[i] = "low"
+		if x[i][0] > 0.5 {
+			classes[i] = "high"
+		}
+		values[i] = 3 * x[i][0]
+	}
+	return x, classes, values
+}
+
+// checkSignalBeatsNoise fails unless the informative column 0 scores clearly
+// above the noise column 1.
+func checkSignalBeatsNoise(t *testing.T, name string, imp *FeatureImportance, floor float64) {
+	t.Helper()
+	if imp.Mean[0] < floor || imp.Mean[1] > imp.Mean[0]/10 {
+		t.Fatalf("%s: signal %.4f±%.4f, noise %.4f±%.4f", name, imp.Mean[0], imp.Std[0], imp.Mean[1], imp.Std[1])
+	}
+}
+
+func TestPermutationImportanceFindsSignal(t *testing.T) {
+	x, classes, values := signalAndNoise(400, 1)
+	testX, testClasses, testValues := signalAndNoise(200, 2)
+
+	classifier := NewClassificationForest[float64, string](1000, 30, 1, 1)
+	classifier.MaxDepth = 10
+	classifier.Seed = 1
+	if err := classifier.TryTrain(x, classes, 30); err != nil {
+		t.Fatal(err)
+	}
+	imp, err := classifier.PermutationImportance(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSignalBeatsNoise(t, "classification out-of-bag", imp, 0.2)
+	imp, err = classifier.PermutationImportanceOn(testX, testClasses, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSignalBeatsNoise(t, "classification validation", imp, 0.2)
+
+	regressor := NewRegressionForest[float64](1000, 30, 1, 1)
+	regressor.Seed = 1
+	if err := regressor.TryTrain(x, values, 30); err != nil {
+		t.Fatal(err)
+	}
+	imp, err = regressor.PermutationImportance(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSignalBeatsNoise(t, "regression out-of-bag", imp, 0.5)
+	imp, err = regressor.PermutationImportanceOn(testX, testValues, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSignalBeatsNoise(t, "regression validation", imp, 0.5)
+}
+
+func TestPermutationImportanceLoadedNoOOB(t *testing.T) {
+	x, classes, values := signalAndNoise(100, 1)
+
+	classifier := NewClassificationForest[float64, string](1000, 5, 1, 1)
+	classifier.MaxDepth = 10
+	if err := classifier.TryTrain(x, classes, 5); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := classifier.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &ClassificationForest[float64, string]{}
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loaded.PermutationImportance(1); !errors.Is(err, ErrNoOOB) {
+		t.Fatalf("classification: got %v without training rows, want %v", err, ErrNoOOB)
+	}
+	if _, err := loaded.PermutationImportanceOn(x, classes, 1); err != nil {
+		t.Fatalf("classification: got %v on a validation set", err)
+	}
+
+	regressor := NewRegressionForest[float64](1000, 5, 1, 1)
+	if err := regressor.TryTrain(x, values, 5); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := regressor.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loadedRegressor := &RegressionForest[float64]{}
+	if err := loadedRegressor.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadedRegressor.PermutationImportance(1); !errors.Is(err, ErrNoOOB) {
+		t.Fatalf("regression: got %v without training rows, want %v", err, ErrNoOOB)
+	}
+}
+
+func TestMeanDecreaseImpuritySumsToOne(t *testing.T) {
+	x, y := loadIris(t)
+	classifier := NewClassificationForest[float64, string](1000, 20, 1, 0.5)
+	classifier.MaxDepth = 10
+	if err := classifier.TryTrain(x, y, 20); err != nil {
+		t.Fatal(err)
+	}
+	forest, _ := trainedSin(t)
+	for name, imp := range map[string][]float64{
+		"classification": classifier.MeanDecreaseImpurity(),
+		"regression":     forest.MeanDecreaseImpurity(),
+	} {
+		if s := sum(imp); math.Abs(s-1) > 1e-9 {
+			t.Fatalf("%s: %v sums to %v", name, imp, s)
+		}
+	}
+}
+
+func TestMeanDecreaseImpurityStump(t *testing.T) {
+	x, y := loadIris(t)
+	forest := NewClassificationForest[float64, string](1000, 1, 1, 1)
+	forest.MaxDepth = 1
+	forest.Seed = 1
+	if err := forest.TryTrain(x, y, 1); err != nil {
+		t.Fatal(err)
+	}
+	root := forest.Trees[0].Root
+	if root.isLeaf() || !root.Left.isLeaf() || !root.Right.isLeaf() {
+		t.Fatal("want a single split")
+	}
+	if root.Column == 0 {
+		t.Fatal("want a split off column 0, which Importance charges the leaves to")
+	}
+	mdi := forest.MeanDecreaseImpurity()
+	for c, v := range mdi {
+		want := 0.0
+		if c == root.Column {
+			want = 1
+		}
+		if v != want {
+			t.Fatalf("column %d: got %v, want %v from a stump on column %d", c, v, want, root.Column)
+		}
+	}
+	// the impure leaf adds its entropy to column 0 in Importance
+	if imp := forest.Importance(); imp[0] <= 0 || imp[root.Column] >= 1 {
+		t.Fatalf("Importance %v should differ from MeanDecreaseImpurity %v", imp, mdi)
+	}
+}
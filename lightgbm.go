@@ -0,0 +1,161 @@
+package randomForest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type lightgbmModel struct {
+	NumClass            int    `json:"num_class"`
+	NumTreePerIteration int    `json:"num_tree_per_iteration"`
+	MaxFeatureIdx       int    `json:"max_feature_idx"`
+	Objective           string `json:"objective"`
+	AverageOutput       bool   `json:"average_output"`
+	TreeInfo            []struct {
+		TreeStructure lightgbmNode `json:"tree_structure"`
+	} `json:"tree_info"`
+}
+
+type lightgbmNode struct {
+	SplitFeature  *int            `json:"split_feature"`
+	Threshold     json.RawMessage `json:"threshold"`
+	DecisionType  string          `json:"decision_type"`
+	DefaultLeft   bool            `json:"default_left"`
+	MissingType   string          `json:"missing_type"`
+	InternalCount int             `json:"internal_count"`
+	LeafValue     float64         `json:"leaf_value"`
+	LeafCount     int             `json:"leaf_count"`
+	LeftChild     *lightgbmNode   `json:"left_child"`
+	RightChild    *lightgbmNode   `json:"right_child"`
+}
+
+// lightgbmLink parses an objective such as "binary sigmoid:1" or
+// "multiclass num_class:3" into its link and the factor applied to the raw
+// scores before it.
+func lightgbmLink(objective string) (Link, float64, error) {
+	fields := strings.Fields(objective)
+	if len(fields) == 0 {
+		return IDENTITY, 1, nil
+	}
+	scale := 1.0
+	for _, f := range fields[1:] {
+		if v, ok := strings.CutPrefix(f, "sigmoid:"); ok {
+			s, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("objective %q: %w", objective, err)
+			}
+			scale = s
+		}
+	}
+	switch fields[0] {
+	case "binary", "multiclassova":
+		return LOGISTIC, scale, nil
+	case "cross_entropy", "xentropy":
+		return LOGISTIC, 1, nil
+	case "multiclass", "softmax":
+		return SOFTMAX, 1, nil
+	case "poisson", "gamma", "tweedie":
+		return EXP, 1, nil
+	case "regression", "regression_l2", "regression_l1", "huber", "fair", "quantile", "mape", "lambdarank", "rank_xendcg", "custom":
+		return IDENTITY, 1, nil
+	}
+	return 0, 0, fmt.Errorf("unsupported objective %q", objective)
+}
+
+func convertLightGBMNode[F Feature](node *lightgbmNode, features, depth int, scale float64) (*RegressionNode[F], error) {
+	if depth > maxDecodeDepth {
+		return nil, errors.New("tree too deep")
+	}
+	if node.SplitFeature == nil {
+		return &RegressionNode[F]{Size: node.LeafCount, Label: node.LeafValue * scale}, nil
+	}
+	c := *node.SplitFeature
+	if c < 0 || c >= features {
+		return nil, fmt.Errorf("feature %d of %d", c, features)
+	}
+	if node.LeftChild == nil || node.RightChild == nil {
+		return nil, errors.New("split without children")
+	}
+	split := &RegressionNode[F]{Size: node.InternalCount, Column: c}
+	switch node.DecisionType {
+	case "<=":
+		var t float64
+		if err := json.Unmarshal(node.Threshold, &t); err != nil {
+			return nil, err
+		}
+		value, err := threshold[F](t, false)
+		if err != nil {
+			return nil, err
+		}
+		split.Type, split.Value = NUMERIC, &value
+		switch node.MissingType {
+		case "NaN":
+			split.MissingLeft = node.DefaultLeft
+		case "Zero":
+			var zero F
+			split.Missing, split.MissingLeft = &zero, node.DefaultLeft
+		default:
+			split.MissingLeft = 0 <= t
+		}
+	case "==":
+		var set string
+		if err := json.Unmarshal(node.Threshold, &set); err != nil {
+			set = string(node.Threshold)
+		}
+		for _, s := range strings.Split(set, "||") {
+			v, err := parseFeature[F](s)
+			if err != nil {
+				return nil, err
+			}
+			split.Categories = append(split.Categories, v)
+		}
+		slices.Sort(split.Categories)
+		split.Type = CAT
+	default:
+		return nil, fmt.Errorf("unsupported decision type %q", node.DecisionType)
+	}
+	var err error
+	if split.Left, err = convertLightGBMNode[F](node.LeftChild, features, depth+1, scale); err != nil {
+		return nil, err
+	}
+	if split.Right, err = convertLightGBMNode[F](node.RightChild, features, depth+1, scale); err != nil {
+		return nil, err
+	}
+	return split, nil
+}
+
+// ReadLightGBMDump reads a LightGBM model dumped as JSON by
+// Booster.dump_model. Multiclass models have one tree per class and
+// iteration, assigned round-robin; random forest models average instead of
+// summing. The sigmoid parameter of binary objectives is folded into the
+// leaf values. Categorical splits match a feature equal to one of their
+// categories, while LightGBM truncates it to an integer first: 1.5 falls in
+// category 1 there but goes right here, so pass integral category codes.
+func ReadLightGBMDump[F Feature](r io.Reader) (*BoostedForest[F], error) {
+	var dump lightgbmModel
+	if err := json.NewDecoder(r).Decode(&dump); err != nil {
+		return nil, corruptModel(err)
+	}
+	link, scale, err := lightgbmLink(dump.Objective)
+	if err != nil {
+		return nil, err
+	}
+	outputs := max(dump.NumTreePerIteration, 1)
+	forest := &BoostedForest[F]{Features: dump.MaxFeatureIdx + 1, Outputs: outputs, Link: link, Average: dump.AverageOutput}
+	for t := range dump.TreeInfo {
+		root, err := convertLightGBMNode[F](&dump.TreeInfo[t].TreeStructure, forest.Features, 0, scale)
+		if err != nil {
+			return nil, corruptModel(fmt.Errorf("tree %d: %w", t, err))
+		}
+		forest.Trees = append(forest.Trees, &RegressionTree[F]{Root: root})
+	}
+	if len(forest.Trees) == 0 {
+		return nil, corruptModel(errors.New("no trees"))
+	}
+	return forest, nil
+}
@@ -0,0 +1,66 @@
+package randomForest
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// lightgbmMulticlass is one iteration of a three-class model: a numeric
+// split with NaN missing values, a categorical split and a zero-as-missing
+// split.
+const lightgbmMulticlass = `{"name":"tree","version":"v3","num_class":3,"num_tree_per_iteration":3,"label_index":0,"max_feature_idx":3,
+"objective":"multiclass num_class:3","average_output":false,"feature_names":["a","b","c","d"],"tree_info":[
+ {"tree_index":0,"tree_structure":{"split_index":0,"split_feature":2,"threshold":2.45,"decision_type":"<=","default_left":true,"missing_type":"NaN","internal_count":150,
+   "left_child":{"leaf_index":0,"leaf_value":1.0,"leaf_count":50},"right_child":{"leaf_index":1,"leaf_value":-1.0,"leaf_count":100}}},
+ {"tree_index":1,"tree_structure":{"split_index":0,"split_feature":0,"threshold":"1||3","decision_type":"==","default_left":false,"missing_type":"None","internal_count":150,
+   "left_child":{"leaf_value":0.5},"right_child":{"leaf_value":0}}},
+ {"tree_index":2,"tree_structure":{"split_index":0,"split_feature":1,"threshold":1e-35,"decision_type":"<=","default_left":false,"missing_type":"Zero",
+   "left_child":{"leaf_value":2},"right_child":{"leaf_value":-2}}}
+]}`
+
+func softmax(margins ...float64) []float64 {
+	total := 0.0
+	for _, m := range margins {
+		total += math.Exp(m)
+	}
+	out := make([]float64, len(margins))
+	for k, m := range margins {
+		out[k] = math.Exp(m) / total
+	}
+	return out
+}
+
+func TestLightGBMMulticlass(t *testing.T) {
+	forest, err := ReadLightGBMDump[float64](strings.NewReader(lightgbmMulticlass))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nan := math.NaN()
+	golden := []struct {
+		input []float64
+		class int
+		want  []float64
+	}{
+		{[]float64{1, 0, 1, 0}, 0, softmax(1, 0.5, -2)},
+		{[]float64{1, -1, 1, 0}, 2, softmax(1, 0.5, 2)},
+		{[]float64{2, 1, 3, 0}, 1, softmax(-1, 0, -2)},
+		{[]float64{3, nan, nan, 0}, 0, softmax(1, 0.5, -2)},
+		// LightGBM would truncate 1.5 to category 1
+		{[]float64{1.5, 0, 1, 0}, 0, softmax(1, 0, -2)},
+	}
+	for _, g := range golden {
+		proba := forest.PredictProba(g.input)
+		if len(proba) != len(g.want) {
+			t.Fatalf("%v: got %v, want %v", g.input, proba, g.want)
+		}
+		for k := range proba {
+			if math.Abs(proba[k]-g.want[k]) > 1e-12 {
+				t.Fatalf("%v: got %v, want %v", g.input, proba, g.want)
+			}
+		}
+		if got := forest.PredictClass(g.input); got != g.class {
+			t.Fatalf("%v: got class %d, want %d", g.input, got, g.class)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package randomForest
+
+import (
+	"cmp"
+	"slices"
+)
+
+// MissingMarker declares Value as the missing value of Column, e.g. "" or
+// -1. NaN is always treated as missing.
+type MissingMarker[F Feature] struct {
+	Column int
+	Value  F
+}
+
+// Surrogate is a backup split used when the column of its node is missing.
+// It sends a row left when its test passes, or fails if Reverse is set.
+type Surrogate[F Feature] struct {
+	Column     int
+	Type       ColumnType
+	Value      *F  `json:",omitempty" bson:",omitempty"`
+	Categories []F `json:",omitempty" bson:",omitempty"`
+	Missing    *F  `json:",omitempty" bson:",omitempty"`
+	Reverse    bool
+	Agreement  float64
+}
+
+func isMissing[F Feature](value F, marker *F) bool {
+	return value != value || (marker != nil && value == *marker)
+}
+
+func testLeft[F Feature](column_type ColumnType, value *F, categories []F, x F) bool {
+	if column_type == NUMERIC {
+		return x <= *value
+	}
+	if categories != nil {
+		_, ok := slices.BinarySearch(categories, x)
+		return ok
+	}
+	return x == *value
+}
+
+// missingGoesLeft picks the direction of a row whose split column is missing:
+// the first surrogate with a present column decides, otherwise the learned
+// default does.
+func missingGoesLeft[F Feature](surrogates []Surrogate[F], input []F, missingLeft bool) bool {
+	for _, s := range surrogates {
+		if value := input[s.Column]; !isMissing(value, s.Missing) {
+			return testLeft(s.Type, s.Value, s.Categories, value) != s.Reverse
+		}
+	}
+	return missingLeft
+}
+
+func (cfg *growConfig[F]) missingMarker(c int) *F {
+	for i := range cfg.missing {
+		if cfg.missing[i].Column == c {
+			return &cfg.missing[i].Value
+		}
+	}
+	return nil
+}
+
+func presentRows[F Feature](samples [][]F, c int, marker *F) []int {
+	present := make([]int, 0, len(samples))
+	for j, row := range samples {
+		if !isMissing(row[c], marker) {
+			present = append(present, j)
+		}
+	}
+	return present
+}
+
+// getBestGainWithMissing searches the split of column c on the rows where it
+// is present, then sends the missing rows to whichever side gains more.
+func getBestGainWithMissing[F Feature, L Label](cfg *growConfig[F], criterion SplitCriterion[L], samples [][]F, rows []int, c int, labels []L, column_type ColumnType, current_entropy float64) split[F] {
+	marker := cfg.missingMarker(c)
+	present := presentRows(samples, c, marker)
+	if len(present) == len(samples) {
+		s := getBestGain(criterion, samples, c, labels, column_type, current_entropy, cfg.binned(c, rows))
+		s.missing = marker
+		s.missingLeft = s.totalL >= s.totalR
+		return s
+	}
+	if len(present) < 2 {
+		return split[F]{}
+	}
+
+	present_labels := getLabels(labels, present)
+	present_map := make(map[L]float64)
+	for _, l := range present_labels {
+		present_map[l] += 1
+	}
+	s := getBestGain(criterion, getSamples(samples, present), c, present_labels, column_type, criterion.Impurity(present_map, len(present)), cfg.binned(c, getLabels(rows, present)))
+	if s.gain <= 0 {
+		return split[F]{}
+	}
+	s.missing = marker
+
+	best := split[F]{}
+	for _, missingLeft := range []bool{false, true} {
+		s.missingLeft = missingLeft
+		map_l := make(map[L]float64)
+		map_r := make(map[L]float64)
+		s.totalL, s.totalR = 0, 0
+		for j, row := range samples {
+			if s.goesLeft(column_type, row[c]) {
+				s.totalL += 1
+				map_l[labels[j]] += 1.0
+			} else {
+				s.totalR += 1
+				map_r[labels[j]] += 1.0
+			}
+		}
+		p1 := float64(s.totalR) / float64(len(samples))
+		p2 := float64(s.totalL) / float64(len(samples))
+		s.gain = current_entropy - (p1*criterion.Impurity(map_r, s.totalR) + p2*criterion.Impurity(map_l, s.totalL))
+		if s.gain >= best.gain {
+			best = s
+		}
+	}
+	return best
+}
+
+func getBestMSEGainWithMissing[F Feature](cfg *growConfig[F], samples [][]F, rows []int, c int, labels []float64, column_type ColumnType, current_mse float64) split[F] {
+	marker := cfg.missingMarker(c)
+	present := presentRows(samples, c, marker)
+	if len(present) == len(samples) {
+		s := getBestMSEGain(samples, c, labels, column_type, current_mse, cfg.binned(c, rows))
+		s.missing = marker
+		s.missingLeft = s.totalL >= s.totalR
+		return s
+	}
+	if len(present) < 2 {
+		return split[F]{}
+	}
+
+	present_labels := getLabels(labels, present)
+	s := getBestMSEGain(getSamples(samples, present), c, present_labels, column_type, getMSE(present_labels), cfg.binned(c, getLabels(rows, present)))
+	if s.gain <= 0 {
+		return split[F]{}
+	}
+	s.missing = marker
+
+	best := split[F]{}
+	for _, missingLeft := range []bool{false, true} {
+		s.missingLeft = missingLeft
+		labels_l := make([]float64, 0, len(samples))
+		labels_r := make([]float64, 0, len(samples))
+		for j, row := range samples {
+			if s.goesLeft(column_type, row[c]) {
+				labels_l = append(labels_l, labels[j])
+			} else {
+				labels_r = append(labels_r, labels[j])
+			}
+		}
+		s.totalL, s.totalR = len(labels_l), len(labels_r)
+		p1 := float64(s.totalR) / float64(len(samples))
+		p2 := float64(s.totalL) / float64(len(samples))
+		s.gain = current_mse - (p1*getMSE(labels_r) + p2*getMSE(labels_l))
+		if s.gain >= best.gain {
+			best = s
+		}
+	}
+	return best
+}
+
+// findSurrogates ranks the other columns by how well a single split on them
+// reproduces the primary split, keeping those that beat sending every row to
+// the majority side.
+func findSurrogates[F Feature](cfg *growConfig[F], samples [][]F, column int, column_type ColumnType, primary split[F]) []Surrogate[F] {
+	surrogates := make([]Surrogate[F], 0)
+	for c := 0; c < len(samples[0]); c++ {
+		if c == column {
+			continue
+		}
+		marker := cfg.missingMarker(c)
+		rows := make([]int, 0, len(samples))
+		left := make([]bool, len(samples))
+		n_left := 0
+		for j, row := range samples {
+			if isMissing(row[column], primary.missing) || isMissing(row[c], marker) {
+				continue
+			}
+			rows = append(rows, j)
+			left[j] = primary.goesLeft(column_type, row[column])
+			if left[j] {
+				n_left += 1
+			}
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		baseline := max(n_left, len(rows)-n_left)
+
+		sur := Surrogate[F]{Column: c, Type: cfg.columnType(c, samples[0][c]), Missing: marker}
+		agree := 0
+		if sur.Type == NUMERIC {
+			slices.SortFunc(rows, func(a, b int) int {
+				return cmp.Compare(samples[a][c], samples[b][c])
+			})
+			left_left := 0
+			for k, j := range rows[:len(rows)-1] {
+				if left[j] {
+					left_left += 1
+				}
+				if samples[rows[k+1]][c] == samples[j][c] {
+					continue
+				}
+				right_left := k + 1 - left_left
+				forward := left_left + (len(rows) - n_left - right_left)
+				reverse := right_left + (n_left - left_left)
+				if forward > agree || reverse > agree {
+					value := samples[j][c]
+					sur.Value = &value
+					sur.Reverse = reverse > forward
+					agree = max(forward, reverse)
+				}
+			}
+		} else {
+			votes := make(map[F]int)
+			for _, j := range rows {
+				if left[j] {
+					votes[samples[j][c]] += 1
+				} else {
+					votes[samples[j][c]] -= 1
+				}
+			}
+			sur.Categories = make([]F, 0)
+			for _, v := range sortedKeys(votes) {
+				if votes[v] > 0 {
+					sur.Categories = append(sur.Categories, v)
+				}
+			}
+			for _, j := range rows {
+				if testLeft(sur.Type, nil, sur.Categories, samples[j][c]) == left[j] {
+					agree += 1
+				}
+			}
+		}
+		if agree > baseline && (sur.Value != nil || len(sur.Categories) > 0) {
+			sur.Agreement = float64(agree) / float64(len(rows))
+			surrogates = append(surrogates, sur)
+		}
+	}
+	slices.SortStableFunc(surrogates, func(a, b Surrogate[F]) int {
+		return cmp.Compare(b.Agreement, a.Agreement)
+	})
+	return surrogates[:min(len(surrogates), cfg.maxSurrogates)]
+}
@@ -0,0 +1,131 @@
+package randomForest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// blank returns a copy of x with about fraction of its cells set to missing.
+func blank[F Feature](x [][]F, fraction float64, missing F) [][]F {
+	rng := rand.New(rand.NewSource(1))
+	out := make([][]F, len(x))
+	for i, row := range x {
+		out[i] = append([]F{}, row...)
+		for c := range row {
+			if rng.Float64() < fraction {
+				out[i][c] = missing
+			}
+		}
+	}
+	return out
+}
+
+// splitStats counts the splits below node that send missing rows left and
+// right, and those with surrogates.
+func splitStats[F Feature, L Label](node *ClassificationNode[F, L], left, right, surrogates *int) {
+	if node == nil || node.isLeaf() {
+		return
+	}
+	if node.MissingLeft {
+		*left++
+	} else {
+		*right++
+	}
+	if len(node.Surrogates) > 0 {
+		*surrogates++
+	}
+	splitStats(node.Left, left, right, surrogates)
+	splitStats(node.Right, left, right, surrogates)
+}
+
+func eachSplit[F Feature, L Label](node *ClassificationNode[F, L], f func(node *ClassificationNode[F, L])) {
+	if node == nil || node.isLeaf() {
+		return
+	}
+	f(node)
+	eachSplit(node.Left, f)
+	eachSplit(node.Right, f)
+}
+
+func accuracy[F Feature, L Label](forest *ClassificationForest[F, L], x [][]F, y []L) float64 {
+	correct := 0
+	for i := range x {
+		if forest.Predicate(x[i]) == y[i] {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(x))
+}
+
+// changed counts the rows whose prediction changes after modify.
+func changed[F Feature, L Label](forest *ClassificationForest[F, L], x [][]F, modify func(node *ClassificationNode[F, L])) int {
+	before := make([]L, len(x))
+	for i := range x {
+		before[i] = forest.Predicate(x[i])
+	}
+	for _, tree := range forest.Trees {
+		eachSplit(tree.Root, modify)
+	}
+	n := 0
+	for i := range x {
+		if forest.Predicate(x[i]) != before[i] {
+			n++
+		}
+	}
+	return n
+}
+
+func testMissing[F Feature, L Label](t *testing.T, x [][]F, y []L, forest *ClassificationForest[F, L], minAccuracy float64) {
+	forest.MaxDepth = 10
+	forest.MaxSurrogates = 2
+	forest.Seed = 1
+	if err := forest.TryTrain(x, y, 20); err != nil {
+		t.Fatal(err)
+	}
+	if acc := accuracy(forest, x, y); acc < minAccuracy {
+		t.Fatalf("accuracy %.3f with missing cells, want at least %.2f", acc, minAccuracy)
+	}
+	left, right, surrogates := 0, 0, 0
+	for _, tree := range forest.Trees {
+		splitStats(tree.Root, &left, &right, &surrogates)
+	}
+	if left == 0 || right == 0 || surrogates == 0 {
+		t.Fatalf("%d splits send missing rows left, %d right, %d have surrogates", left, right, surrogates)
+	}
+	if n := changed(forest, x, func(node *ClassificationNode[F, L]) { node.Surrogates = nil }); n == 0 {
+		t.Fatal("dropping the surrogates changes no prediction")
+	}
+	if n := changed(forest, x, func(node *ClassificationNode[F, L]) { node.MissingLeft = !node.MissingLeft }); n == 0 {
+		t.Fatal("flipping MissingLeft changes no prediction")
+	}
+}
+
+func TestMissingIris(t *testing.T) {
+	x, y := loadIris(t)
+	testMissing(t, blank(x, 0.2, math.NaN()), y, NewClassificationForest[float64, string](1000, 20, 1, 0.5), 0.85)
+}
+
+func TestMissingCars(t *testing.T) {
+	x, y := loadCars(t)
+	forest := NewClassificationForest[string, string](2000, 20, 1, 0.5)
+	for c := range x[0] {
+		forest.Missing = append(forest.Missing, MissingMarker[string]{Column: c, Value: "?"})
+	}
+	testMissing(t, blank(x, 0.2, "?"), y, forest, 0.8)
+}
+
+// Rows missing every column must still reach a leaf.
+func TestMissingEverything(t *testing.T) {
+	x, y := loadIris(t)
+	forest := NewClassificationForest[float64, string](1000, 10, 1, 0.5)
+	forest.MaxDepth = 10
+	forest.MaxSurrogates = 2
+	if err := forest.TryTrain(blank(x, 0.2, math.NaN()), y, 10); err != nil {
+		t.Fatal(err)
+	}
+	nan := math.NaN()
+	if proba := forest.PredicateWithData([]float64{nan, nan, nan, nan}); len(proba) == 0 {
+		t.Fatal("no prediction for a row of missing values")
+	}
+}
@@ -4,8 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math"
-	"sync"
-	"time"
+	"math/rand"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -26,6 +25,11 @@ type MongoForest[F Feature] struct {
 	database *mongo.Database `bson:"-"`
 }
 
+// MeanDecreaseImpurity works like RegressionForest.MeanDecreaseImpurity.
+func (f MongoForest[F]) MeanDecreaseImpurity() []float64 {
+	return meanDecreaseImpurity(f.Trees, f.Features)
+}
+
 func (f MongoForest[F]) Importance() []float64 {
 	imp := make([]float64, f.Features)
 	for i := 0; i < len(f.Trees); i++ {
@@ -57,68 +61,111 @@ func NewMongoForest[F Feature](database *mongo.Database, treeCount int, samplesA
 }
 
 func (forest *MongoForest[F]) Train() {
-	prog_counter := 0
-	mutex := &sync.Mutex{}
-	s := make(chan bool, NUM_CPU)
-	for i := 0; i < forest.TreeLimit; i++ {
-		s <- true
-		go func(x int) {
-			defer func() { <-s }()
-
-			fmt.Printf(">> %v buiding %vth tree...\n", time.Now(), x)
-			tree := forest.BuildTree()
-			forest.Trees = append(forest.Trees, tree)
-			//fmt.Printf("<< %v the %vth tree is done.\n",time.Now(), x)
-			mutex.Lock()
-			prog_counter += 1
-			fmt.Printf("%v tranning progress %.0f%%\n", time.Now(), float64(prog_counter)/float64(forest.TreeLimit)*100)
-			mutex.Unlock()
-		}(i)
+	if err := forest.TryTrain(); err != nil {
+		panic(err)
 	}
+}
 
-	for i := 0; i < NUM_CPU; i++ {
-		s <- true
-	}
+func (forest *MongoForest[F]) TryTrain() error {
+	return forest.TrainContext(context.Background())
+}
+
+// TrainContext adds TreeLimit trees. If ctx is cancelled it keeps the trees
+// finished by then and returns ctx.Err().
+func (forest *MongoForest[F]) TrainContext(ctx context.Context) error {
+	seed := forest.trainingSeed()
+	offset := len(forest.Trees)
+	trees, err := buildTrees(ctx, forest.progress(), forest.TreeLimit, func(ctx context.Context, x int) (*RegressionTree[F], error) {
+		return forest.buildTree(ctx, treeRand(seed, offset+x))
+	})
+	forest.Trees = append(forest.Trees, trees...)
+	return err
 }
 
-func getData(collection mongo.Collection, count int) (*mongo.Cursor, error) {
+func getData(ctx context.Context, collection mongo.Collection, count int) (*mongo.Cursor, error) {
 	pipeline := mongo.Pipeline([]bson.D{{{Key: "$sample", Value: bson.D{{Key: "size", Value: count}}}}})
-	return collection.Aggregate(context.Background(), pipeline)
+	return collection.Aggregate(ctx, pipeline)
 }
 
 func (forest *MongoForest[F]) BuildTree() *RegressionTree[F] {
-	cursor, err := getData(*forest.database.Collection(fmt.Sprintf("steps_%s", forest.Game)), forest.NSize)
+	tree, err := forest.TryBuildTree()
 	if err != nil {
 		panic(err)
 	}
+	return tree
+}
+
+func (forest *MongoForest[F]) TryBuildTree() (*RegressionTree[F], error) {
+	return forest.buildTree(context.Background(), newRand())
+}
+
+func (forest *MongoForest[F]) buildTree(ctx context.Context, rng *rand.Rand) (*RegressionTree[F], error) {
+	collection := forest.database.Collection(fmt.Sprintf("steps_%s", forest.Game))
+	cursor, err := getData(ctx, *collection, forest.NSize)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
 	samples := make([][]F, 0, forest.NSize)
 	samples_labels := make([]float64, 0, forest.NSize)
-	for cursor.Next(context.Background()) {
+	for cursor.Next(ctx) {
 		var data DataDTO[F]
-		err := cursor.Decode(&data)
-		if err != nil {
-			panic(err)
+		if err := cursor.Decode(&data); err != nil {
+			return nil, err
 		}
 		samples = append(samples, data.Input)
 		samples_labels = append(samples_labels, data.Reward)
 	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, ErrEmptyInput
+	}
+	if err := validateInputs(samples, len(samples_labels), 0); err != nil {
+		return nil, err
+	}
+	if err := forest.validateSchema(len(samples[0])); err != nil {
+		return nil, err
+	}
+	if err := forest.validateBins(); err != nil {
+		return nil, err
+	}
 
 	tree := &RegressionTree[F]{}
-	tree.Root = buildRegressionNode(samples, samples_labels, forest.MFeatures, forest.MaxDepth)
+	cfg := forest.growConfig(ctx, rng, min(forest.MFeatures, len(samples[0])))
+	if forest.MaxBins > 0 {
+		cfg.bins = computeBins(samples, forest.MaxBins, forest.Schema, forest.Missing)
+	}
+	tree.Root = buildRegressionNode(cfg, samples, allRows(len(samples)), samples_labels, forest.MaxDepth)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	count := 0
 	e := 0.0
-	cursor, err = getData(*forest.database.Collection(fmt.Sprintf("steps_%s", forest.Game)), forest.NSize/10)
+	validation, err := getData(ctx, *collection, forest.NSize/10)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	for cursor.Next(context.Background()) {
+	defer validation.Close(ctx)
+	for validation.Next(ctx) {
 		var data DataDTO[F]
-		cursor.Decode(&data)
+		if err := validation.Decode(&data); err != nil {
+			return nil, err
+		}
+		if len(data.Input) != len(samples[0]) {
+			return nil, fmt.Errorf("%w: validation row has %d features, want %d", ErrRaggedFeatures, len(data.Input), len(samples[0]))
+		}
 		count++
 		e += tree.Predicate(data.Input)
 	}
-	tree.Validation = math.Abs(e / float64(count))
-	return tree
+	if err := validation.Err(); err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		tree.Validation = math.Abs(e / float64(count))
+	}
+	return tree, nil
 }
 
 func (forest *MongoForest[F]) Predicate(input []F) float64 {
@@ -146,20 +193,42 @@ func (forest *MongoForest[F]) WeightedPredicate(input []F) float64 {
 }
 
 func (forest *MongoForest[F]) DumpForest() {
+	if err := forest.Dump(); err != nil {
+		panic(err)
+	}
+}
+
+func (forest *MongoForest[F]) Dump() error {
 	upsert := true
 	_, err := forest.database.Collection("forests").ReplaceOne(context.Background(), bson.D{{Key: "game", Value: forest.Game}}, forest, &options.ReplaceOptions{Upsert: &upsert})
+	return err
+}
+
+func LoadMongoForest[F Feature](database *mongo.Database, game string) *MongoForest[F] {
+	forest, err := ReadMongoForest[F](database, game)
 	if err != nil {
 		panic(err)
 	}
+	return forest
 }
 
-func LoadMongoForest[F Feature](database *mongo.Database, game string) *MongoForest[F] {
+func ReadMongoForest[F Feature](database *mongo.Database, game string) (*MongoForest[F], error) {
 	result := database.Collection("forests").FindOne(context.Background(), bson.D{{Key: "game", Value: game}})
 	if result.Err() != nil {
-		panic(result.Err())
+		return nil, result.Err()
 	}
 	var forest MongoForest[F]
-	result.Decode(&forest)
+	if err := result.Decode(&forest); err != nil {
+		return nil, corruptModel(err)
+	}
+	raw, err := result.Raw()
+	if err != nil {
+		return nil, err
+	}
+	// Forests dumped before schemas existed carry no column types.
+	if _, err := raw.LookupErr("baseforest", "schema"); err != nil {
+		inferRegressionTypes(forest.Trees)
+	}
 	forest.database = database
-	return &forest
+	return &forest, nil
 }
@@ -0,0 +1,406 @@
+package randomForest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// protoWriter encodes protocol buffer messages field by field.
+type protoWriter struct {
+	buf []byte
+}
+
+func (p *protoWriter) tag(field, wire int) {
+	p.buf = binary.AppendUvarint(p.buf, uint64(field<<3|wire))
+}
+
+func (p *protoWriter) int(field int, v int64) {
+	p.tag(field, 0)
+	p.buf = binary.AppendUvarint(p.buf, uint64(v))
+}
+
+func (p *protoWriter) bytes(field int, b []byte) {
+	p.tag(field, 2)
+	p.buf = binary.AppendUvarint(p.buf, uint64(len(b)))
+	p.buf = append(p.buf, b...)
+}
+
+func (p *protoWriter) string(field int, s string) {
+	p.bytes(field, []byte(s))
+}
+
+func (p *protoWriter) message(field int, build func(m *protoWriter)) {
+	m := &protoWriter{}
+	build(m)
+	p.bytes(field, m.buf)
+}
+
+func (p *protoWriter) floats(field int, v []float32) {
+	b := make([]byte, 0, 4*len(v))
+	for _, x := range v {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(x))
+	}
+	p.bytes(field, b)
+}
+
+func (p *protoWriter) ints(field int, v []int64) {
+	b := make([]byte, 0, len(v))
+	for _, x := range v {
+		b = binary.AppendUvarint(b, uint64(x))
+	}
+	p.bytes(field, b)
+}
+
+// ONNX attribute types and tensor element types.
+const (
+	onnxAttrInt     = 2
+	onnxAttrString  = 3
+	onnxAttrFloats  = 6
+	onnxAttrInts    = 7
+	onnxAttrStrings = 8
+
+	onnxFloat  = 1
+	onnxInt64  = 7
+	onnxString = 8
+)
+
+func attrInt(m *protoWriter, name string, v int64) {
+	m.message(5, func(a *protoWriter) {
+		a.string(1, name)
+		a.int(3, v)
+		a.int(20, onnxAttrInt)
+	})
+}
+
+func attrString(m *protoWriter, name, v string) {
+	m.message(5, func(a *protoWriter) {
+		a.string(1, name)
+		a.string(4, v)
+		a.int(20, onnxAttrString)
+	})
+}
+
+func attrInts(m *protoWriter, name string, v []int64) {
+	m.message(5, func(a *protoWriter) {
+		a.string(1, name)
+		a.ints(8, v)
+		a.int(20, onnxAttrInts)
+	})
+}
+
+func attrFloats(m *protoWriter, name string, v []float32) {
+	m.message(5, func(a *protoWriter) {
+		a.string(1, name)
+		a.floats(7, v)
+		a.int(20, onnxAttrFloats)
+	})
+}
+
+func attrStrings(m *protoWriter, name string, v []string) {
+	m.message(5, func(a *protoWriter) {
+		a.string(1, name)
+		for _, s := range v {
+			a.string(9, s)
+		}
+		a.int(20, onnxAttrStrings)
+	})
+}
+
+// valueInfo writes a tensor ValueInfoProto with a dynamic first dimension.
+func valueInfo(g *protoWriter, field int, name string, elem int64, dims ...int64) {
+	g.message(field, func(v *protoWriter) {
+		v.string(1, name)
+		v.message(2, func(t *protoWriter) {
+			t.message(1, func(tensor *protoWriter) {
+				tensor.int(1, elem)
+				tensor.message(2, func(shape *protoWriter) {
+					shape.message(1, func(d *protoWriter) { d.string(2, "N") })
+					for _, dim := range dims {
+						shape.message(1, func(d *protoWriter) { d.int(1, dim) })
+					}
+				})
+			})
+		})
+	})
+}
+
+// onnxNodes collects the nodes_* attributes of a tree ensemble and the leaf
+// weights, keyed by tree and node id.
+type onnxNodes struct {
+	treeIDs, nodeIDs, featureIDs, trueIDs, falseIDs, missingTrue []int64
+	modes                                                        []string
+	values                                                       []float32
+	leafTrees, leafNodes, leafIDs                                []int64
+	leafWeights                                                  []float32
+	tree                                                         int64
+	next                                                         int64
+}
+
+func (o *onnxNodes) add(id int64, mode string, feature int, value float32, yes, no int64, missingTrue bool) {
+	o.treeIDs = append(o.treeIDs, o.tree)
+	o.nodeIDs = append(o.nodeIDs, id)
+	o.modes = append(o.modes, mode)
+	o.featureIDs = append(o.featureIDs, int64(feature))
+	o.values = append(o.values, value)
+	o.trueIDs = append(o.trueIDs, yes)
+	o.falseIDs = append(o.falseIDs, no)
+	if missingTrue {
+		o.missingTrue = append(o.missingTrue, 1)
+	} else {
+		o.missingTrue = append(o.missingTrue, 0)
+	}
+}
+
+func (o *onnxNodes) leaf(id int64, target int, weight float64) {
+	o.leafTrees = append(o.leafTrees, o.tree)
+	o.leafNodes = append(o.leafNodes, id)
+	o.leafIDs = append(o.leafIDs, int64(target))
+	o.leafWeights = append(o.leafWeights, float32(weight))
+}
+
+func (o *onnxNodes) id() int64 {
+	o.next++
+	return o.next - 1
+}
+
+// split emits the branch nodes of one split: BRANCH_LEQ for numeric columns,
+// a chain of BRANCH_EQ nodes for categorical ones, and a leading BRANCH_EQ
+// node for a missing marker. NaN follows the learned default direction. The
+// children are already emitted as left and right, so every edge into one
+// points at the same node.
+func (o *onnxNodes) split(id int64, column int, columnType ColumnType, value *float64, categories []float64, missing *float64, missingLeft bool, left, right int64) {
+	if missing != nil {
+		test := o.id()
+		target := right
+		if missingLeft {
+			target = left
+		}
+		o.add(id, "BRANCH_EQ", column, float32(*missing), target, test, false)
+		id = test
+	}
+	if columnType == NUMERIC {
+		o.add(id, "BRANCH_LEQ", column, float32(*value), left, right, missingLeft)
+		return
+	}
+	for k, c := range categories {
+		no := right
+		if k < len(categories)-1 {
+			no = o.id()
+		}
+		o.add(id, "BRANCH_EQ", column, float32(c), left, no, missingLeft)
+		id = no
+	}
+}
+
+// exportSplit converts the split of a node to float64 values for ONNX.
+func exportSplit[F Feature](columnType ColumnType, value *F, categories []F, missing *F, surrogates []Surrogate[F]) (ColumnType, *float64, []float64, *float64, error) {
+	if len(surrogates) > 0 {
+		return 0, nil, nil, nil, fmt.Errorf("%w: surrogate splits", ErrNotExportable)
+	}
+	if columnType == AUTO && value != nil {
+		columnType = inferColumnType(*value)
+	}
+	var v, m *float64
+	if value != nil {
+		x := featureFloat(*value)
+		v = &x
+	}
+	if missing != nil {
+		x := featureFloat(*missing)
+		m = &x
+	}
+	if columnType != NUMERIC && categories == nil {
+		categories = []F{*value}
+	}
+	cats := make([]float64, len(categories))
+	for i, c := range categories {
+		cats[i] = featureFloat(c)
+	}
+	if columnType != NUMERIC && len(cats) == 0 {
+		return 0, nil, nil, nil, fmt.Errorf("%w: empty category set", ErrNotExportable)
+	}
+	return columnType, v, cats, m, nil
+}
+
+func featureFloat[F Feature](v F) float64 {
+	r := reflect.ValueOf(v)
+	if r.Kind() == reflect.Float64 {
+		return r.Float()
+	}
+	return float64(r.Int())
+}
+
+func numericFeatures[F Feature]() error {
+	if reflect.TypeFor[F]().Kind() == reflect.String {
+		return fmt.Errorf("%w: string features", ErrNotExportable)
+	}
+	return nil
+}
+
+func exportClassificationNode[F Feature, L Label](o *onnxNodes, node *ClassificationNode[F, L], index map[L]int, scale float64) (int64, error) {
+	id := o.id()
+	if node.isLeaf() || node.Left == nil || node.Right == nil {
+		labels := node.leafLabels()
+		total := 0.0
+		for _, v := range labels {
+			total += v
+		}
+		o.add(id, "LEAF", 0, 0, 0, 0, false)
+		for _, l := range sortedKeys(labels) {
+			o.leaf(id, index[l], labels[l]/total*scale)
+		}
+		return id, nil
+	}
+	columnType, value, categories, missing, err := exportSplit(node.Type, node.Value, node.Categories, node.Missing, node.Surrogates)
+	if err != nil {
+		return 0, err
+	}
+	left, err := exportClassificationNode(o, node.Left, index, scale)
+	if err != nil {
+		return 0, err
+	}
+	right, err := exportClassificationNode(o, node.Right, index, scale)
+	if err != nil {
+		return 0, err
+	}
+	o.split(id, node.Column, columnType, value, categories, missing, node.MissingLeft, left, right)
+	return id, nil
+}
+
+func exportRegressionNode[F Feature](o *onnxNodes, node *RegressionNode[F]) (int64, error) {
+	id := o.id()
+	if node.isLeaf() || node.Left == nil || node.Right == nil {
+		o.add(id, "LEAF", 0, 0, 0, 0, false)
+		o.leaf(id, 0, node.leafLabel())
+		return id, nil
+	}
+	columnType, value, categories, missing, err := exportSplit(node.Type, node.Value, node.Categories, node.Missing, node.Surrogates)
+	if err != nil {
+		return 0, err
+	}
+	left, err := exportRegressionNode(o, node.Left)
+	if err != nil {
+		return 0, err
+	}
+	right, err := exportRegressionNode(o, node.Right)
+	if err != nil {
+		return 0, err
+	}
+	o.split(id, node.Column, columnType, value, categories, missing, node.MissingLeft, left, right)
+	return id, nil
+}
+
+func (o *onnxNodes) attributes(m *protoWriter) {
+	attrInts(m, "nodes_treeids", o.treeIDs)
+	attrInts(m, "nodes_nodeids", o.nodeIDs)
+	attrStrings(m, "nodes_modes", o.modes)
+	attrInts(m, "nodes_featureids", o.featureIDs)
+	attrFloats(m, "nodes_values", o.values)
+	attrInts(m, "nodes_truenodeids", o.trueIDs)
+	attrInts(m, "nodes_falsenodeids", o.falseIDs)
+	attrInts(m, "nodes_missing_value_tracks_true", o.missingTrue)
+	attrString(m, "post_transform", "NONE")
+}
+
+// writeONNX writes a ModelProto holding a single ai.onnx.ml node.
+func writeONNX(w io.Writer, features int, node func(m *protoWriter), outputs func(g *protoWriter)) error {
+	p := &protoWriter{}
+	p.int(1, 8)
+	p.string(2, "randomForest")
+	p.message(8, func(o *protoWriter) { o.string(1, ""); o.int(2, 17) })
+	p.message(8, func(o *protoWriter) { o.string(1, "ai.onnx.ml"); o.int(2, 3) })
+	p.message(7, func(g *protoWriter) {
+		g.message(1, node)
+		g.string(2, "randomForest")
+		valueInfo(g, 11, "input", onnxFloat, int64(features))
+		outputs(g)
+	})
+	_, err := w.Write(p.buf)
+	return err
+}
+
+// ExportONNX writes the forest as an ONNX model with a single
+// TreeEnsembleClassifier. Its "probabilities" output matches PredictProba
+// without calibration, its "label" output the most probable class. Only
+// numeric feature types without surrogate splits can be exported.
+func (forest *ClassificationForest[F, L]) ExportONNX(w io.Writer) error {
+	if err := numericFeatures[F](); err != nil {
+		return err
+	}
+	labels, index := labelDictionary(forest.Trees, nil)
+	o := &onnxNodes{}
+	for t, tree := range forest.Trees {
+		o.tree, o.next = int64(t), 0
+		if _, err := exportClassificationNode(o, tree.Root, index, 1/float64(len(forest.Trees))); err != nil {
+			return err
+		}
+	}
+	labelType := int64(onnxString)
+	if reflect.TypeFor[L]().Kind() != reflect.String {
+		labelType = onnxInt64
+	}
+	return writeONNX(w, forest.Features, func(m *protoWriter) {
+		m.string(1, "input")
+		m.string(2, "label")
+		m.string(2, "probabilities")
+		m.string(3, "TreeEnsembleClassifier")
+		m.string(4, "TreeEnsembleClassifier")
+		m.string(7, "ai.onnx.ml")
+		o.attributes(m)
+		attrInts(m, "class_treeids", o.leafTrees)
+		attrInts(m, "class_nodeids", o.leafNodes)
+		attrInts(m, "class_ids", o.leafIDs)
+		attrFloats(m, "class_weights", o.leafWeights)
+		if labelType == onnxString {
+			names := make([]string, len(labels))
+			for i, l := range labels {
+				names[i] = reflect.ValueOf(l).String()
+			}
+			attrStrings(m, "classlabels_strings", names)
+		} else {
+			ids := make([]int64, len(labels))
+			for i, l := range labels {
+				ids[i] = reflect.ValueOf(l).Int()
+			}
+			attrInts(m, "classlabels_int64s", ids)
+		}
+	}, func(g *protoWriter) {
+		valueInfo(g, 12, "label", labelType)
+		valueInfo(g, 12, "probabilities", onnxFloat, int64(len(labels)))
+	})
+}
+
+// ExportONNX writes the forest as an ONNX model with a single
+// TreeEnsembleRegressor averaging the trees like Predicate. Only numeric
+// feature types without surrogate splits can be exported.
+func (forest *RegressionForest[F]) ExportONNX(w io.Writer) error {
+	if err := numericFeatures[F](); err != nil {
+		return err
+	}
+	o := &onnxNodes{}
+	for t, tree := range forest.Trees {
+		o.tree, o.next = int64(t), 0
+		if _, err := exportRegressionNode(o, tree.Root); err != nil {
+			return err
+		}
+	}
+	return writeONNX(w, forest.Features, func(m *protoWriter) {
+		m.string(1, "input")
+		m.string(2, "variable")
+		m.string(3, "TreeEnsembleRegressor")
+		m.string(4, "TreeEnsembleRegressor")
+		m.string(7, "ai.onnx.ml")
+		o.attributes(m)
+		attrInt(m, "n_targets", 1)
+		attrString(m, "aggregate_function", "AVERAGE")
+		attrInts(m, "target_treeids", o.leafTrees)
+		attrInts(m, "target_nodeids", o.leafNodes)
+		attrInts(m, "target_ids", o.leafIDs)
+		attrFloats(m, "target_weights", o.leafWeights)
+	}, func(g *protoWriter) {
+		valueInfo(g, 12, "variable", onnxFloat, 1)
+	})
+}
@@ -0,0 +1,135 @@
+package randomForest
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// onnxIris returns iris rows with a categorical column of half-centimetre
+// steps, a -1 missing marker and some NaN cells.
+func onnxIris(t testing.TB) ([][]float64, []string) {
+	x, y := loadIris(t)
+	rng := rand.New(rand.NewSource(3))
+	for i, row := range x {
+		row[1] = math.Round(row[1]*2) / 2
+		if i < 10 {
+			row[2] = -1
+		}
+		if rng.Float64() < 0.1 {
+			row[rng.Intn(4)] = math.NaN()
+		}
+	}
+	return x, y
+}
+
+// onnxSize returns the number of ONNX nodes exporting node once per node
+// takes: one per leaf and numeric split, one per category, and one more for
+// a missing marker.
+func onnxSize[F Feature, L Label](node *ClassificationNode[F, L]) int {
+	if node.isLeaf() {
+		return 1
+	}
+	n := 1
+	if node.Type == CAT {
+		n = max(len(node.Categories), 1)
+	}
+	if node.Missing != nil {
+		n++
+	}
+	return n + onnxSize(node.Left) + onnxSize(node.Right)
+}
+
+func TestONNXClassifierRoundTrip(t *testing.T) {
+	x, y := onnxIris(t)
+	forest := NewClassificationForest[float64, string](1000, 30, 1, 0.5)
+	forest.MaxDepth = 10
+	forest.Seed = 1
+	forest.Schema = []ColumnType{NUMERIC, CAT, NUMERIC, NUMERIC}
+	forest.Missing = []MissingMarker[float64]{{Column: 2, Value: -1}}
+	if err := forest.TryTrain(x, y, 30); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := forest.ExportONNX(&buf); err != nil {
+		t.Fatal(err)
+	}
+	model, err := ReadONNX(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := 0
+	for _, tree := range forest.Trees {
+		size += onnxSize(tree.Root)
+	}
+	if len(model.nodes) != size {
+		t.Fatalf("exported %d nodes, want %d", len(model.nodes), size)
+	}
+	for i, row := range x {
+		proba := forest.PredictProba(row)
+		scores, err := model.Scores(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, l := range model.ClassLabelsStrings {
+			if math.Abs(proba[l]-scores[k]) > 1e-5 {
+				t.Fatalf("row %d: ONNX scores %v, PredictProba %v", i, scores, proba)
+			}
+		}
+		if _, tied := maxTied(proba); !tied {
+			k, err := model.Class(row)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := model.ClassLabelsStrings[k], forest.Predicate(row); got != want {
+				t.Fatalf("row %d: ONNX predicts %v, Predicate %v", i, got, want)
+			}
+		}
+	}
+}
+
+func TestONNXRegressorRoundTrip(t *testing.T) {
+	x, _ := onnxIris(t)
+	labels := make([]float64, len(x))
+	for i := range x {
+		labels[i] = float64(i)
+	}
+	forest := NewRegressionForest[float64](1000, 20, 1, 1)
+	forest.Seed = 1
+	forest.Missing = []MissingMarker[float64]{{Column: 2, Value: -1}}
+	if err := forest.TryTrain(x, labels, 20); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := forest.ExportONNX(&buf); err != nil {
+		t.Fatal(err)
+	}
+	model, err := ReadONNX(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range x {
+		scores, err := model.Scores(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := forest.Predicate(row); math.Abs(scores[0]-want) > 1e-3 {
+			t.Fatalf("row %d: ONNX predicts %v, Predicate %v", i, scores[0], want)
+		}
+	}
+}
+
+func TestONNXRejectsSurrogates(t *testing.T) {
+	x, y := onnxIris(t)
+	forest := NewClassificationForest[float64, string](1000, 5, 1, 0.5)
+	forest.MaxDepth = 10
+	forest.MaxSurrogates = 1
+	if err := forest.TryTrain(x, y, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := forest.ExportONNX(&bytes.Buffer{}); !errors.Is(err, ErrNotExportable) {
+		t.Fatalf("got %v, want %v", err, ErrNotExportable)
+	}
+}
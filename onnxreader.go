@@ -0,0 +1,419 @@
+package randomForest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ONNXEnsemble is a TreeEnsembleClassifier or TreeEnsembleRegressor read
+// from an ONNX model. It scores rows without an ONNX runtime, e.g. to check
+// an exported model against the forest it came from.
+type ONNXEnsemble struct {
+	Classifier         bool
+	ClassLabelsInt64   []int64
+	ClassLabelsStrings []string
+	Targets            int
+	Aggregate          string
+	PostTransform      string
+	BaseValues         []float64
+	nodes              []onnxNode
+	roots              []int
+}
+
+type onnxNode struct {
+	mode        string
+	feature     int
+	value       float32
+	yes, no     int
+	missingTrue bool
+	weights     []onnxWeight
+}
+
+type onnxWeight struct {
+	target int
+	weight float64
+}
+
+// protoField is one field of a protocol buffer message. Varints and fixed
+// values are in v, length-delimited values in data.
+type protoField struct {
+	number int
+	wire   int
+	v      uint64
+	data   []byte
+}
+
+// parseProto splits a protocol buffer message into its fields.
+func parseProto(b []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errors.New("bad field key")
+		}
+		b = b[n:]
+		f := protoField{number: int(key >> 3), wire: int(key & 7)}
+		switch f.wire {
+		case 0:
+			f.v, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, errors.New("bad varint")
+			}
+			b = b[n:]
+		case 1:
+			if len(b) < 8 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			f.v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case 2:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			f.data, b = b[n:n+int(size)], b[n+int(size):]
+		case 5:
+			if len(b) < 4 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			f.v, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d", f.wire)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// onnxAttribute is a decoded AttributeProto.
+type onnxAttribute struct {
+	name    string
+	i       int64
+	s       string
+	floats  []float32
+	ints    []int64
+	strings []string
+}
+
+// parseAttribute decodes an AttributeProto, accepting packed and unpacked
+// repeated fields.
+func parseAttribute(b []byte) (onnxAttribute, error) {
+	var a onnxAttribute
+	fields, err := parseProto(b)
+	if err != nil {
+		return a, err
+	}
+	for _, f := range fields {
+		switch {
+		case f.number == 1 && f.wire == 2:
+			a.name = string(f.data)
+		case f.number == 3 && f.wire == 0:
+			a.i = int64(f.v)
+		case f.number == 4 && f.wire == 2:
+			a.s = string(f.data)
+		case f.number == 7 && f.wire == 5:
+			a.floats = append(a.floats, math.Float32frombits(uint32(f.v)))
+		case f.number == 7 && f.wire == 2:
+			if len(f.data)%4 != 0 {
+				return a, errors.New("bad packed floats")
+			}
+			for k := 0; k < len(f.data); k += 4 {
+				a.floats = append(a.floats, math.Float32frombits(binary.LittleEndian.Uint32(f.data[k:])))
+			}
+		case f.number == 8 && f.wire == 0:
+			a.ints = append(a.ints, int64(f.v))
+		case f.number == 8 && f.wire == 2:
+			for data := f.data; len(data) > 0; {
+				v, n := binary.Uvarint(data)
+				if n <= 0 {
+					return a, errors.New("bad packed ints")
+				}
+				a.ints = append(a.ints, int64(v))
+				data = data[n:]
+			}
+		case f.number == 9 && f.wire == 2:
+			a.strings = append(a.strings, string(f.data))
+		}
+	}
+	return a, nil
+}
+
+// ReadONNX reads the first tree ensemble node of an ONNX model.
+func ReadONNX(r io.Reader) (*ONNXEnsemble, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	model, err := parseProto(b)
+	if err != nil {
+		return nil, corruptModel(err)
+	}
+	for _, m := range model {
+		if m.number != 7 || m.wire != 2 {
+			continue
+		}
+		graph, err := parseProto(m.data)
+		if err != nil {
+			return nil, corruptModel(err)
+		}
+		for _, g := range graph {
+			if g.number != 1 || g.wire != 2 {
+				continue
+			}
+			node, err := parseProto(g.data)
+			if err != nil {
+				return nil, corruptModel(err)
+			}
+			var opType string
+			var attributes []onnxAttribute
+			for _, f := range node {
+				switch {
+				case f.number == 4 && f.wire == 2:
+					opType = string(f.data)
+				case f.number == 5 && f.wire == 2:
+					a, err := parseAttribute(f.data)
+					if err != nil {
+						return nil, corruptModel(err)
+					}
+					attributes = append(attributes, a)
+				}
+			}
+			if opType == "TreeEnsembleClassifier" || opType == "TreeEnsembleRegressor" {
+				ensemble, err := newONNXEnsemble(opType == "TreeEnsembleClassifier", attributes)
+				if err != nil {
+					return nil, corruptModel(err)
+				}
+				return ensemble, nil
+			}
+		}
+	}
+	return nil, corruptModel(errors.New("no tree ensemble node"))
+}
+
+func newONNXEnsemble(classifier bool, attributes []onnxAttribute) (*ONNXEnsemble, error) {
+	e := &ONNXEnsemble{Classifier: classifier, Aggregate: "SUM", PostTransform: "NONE", Targets: 1}
+	attr := make(map[string]onnxAttribute, len(attributes))
+	for _, a := range attributes {
+		attr[a.name] = a
+	}
+	prefix := "target_"
+	if classifier {
+		prefix = "class_"
+		e.ClassLabelsInt64 = attr["classlabels_int64s"].ints
+		e.ClassLabelsStrings = attr["classlabels_strings"].strings
+		e.Targets = len(e.ClassLabelsInt64) + len(e.ClassLabelsStrings)
+	} else {
+		if a, ok := attr["n_targets"]; ok {
+			e.Targets = int(a.i)
+		}
+		if a, ok := attr["aggregate_function"]; ok {
+			e.Aggregate = a.s
+		}
+	}
+	if a, ok := attr["post_transform"]; ok {
+		e.PostTransform = a.s
+	}
+	for _, v := range attr["base_values"].floats {
+		e.BaseValues = append(e.BaseValues, float64(v))
+	}
+	switch e.Aggregate {
+	case "SUM", "AVERAGE", "MIN", "MAX":
+	default:
+		return nil, fmt.Errorf("unsupported aggregate function %q", e.Aggregate)
+	}
+	switch e.PostTransform {
+	case "NONE", "SOFTMAX", "LOGISTIC":
+	default:
+		return nil, fmt.Errorf("unsupported post transform %q", e.PostTransform)
+	}
+	if e.Targets <= 0 || (len(e.BaseValues) > 0 && len(e.BaseValues) != e.Targets) {
+		return nil, errors.New("bad target count")
+	}
+
+	treeIDs, nodeIDs := attr["nodes_treeids"].ints, attr["nodes_nodeids"].ints
+	n := len(nodeIDs)
+	featureIDs, modes, values := attr["nodes_featureids"].ints, attr["nodes_modes"].strings, attr["nodes_values"].floats
+	trueIDs, falseIDs := attr["nodes_truenodeids"].ints, attr["nodes_falsenodeids"].ints
+	missingTrue := attr["nodes_missing_value_tracks_true"].ints
+	if len(treeIDs) != n || len(featureIDs) != n || len(modes) != n || len(values) != n || len(trueIDs) != n || len(falseIDs) != n || (missingTrue != nil && len(missingTrue) != n) {
+		return nil, errors.New("node attributes differ in length")
+	}
+	type key struct{ tree, node int64 }
+	index := make(map[key]int, n)
+	for k := range nodeIDs {
+		index[key{treeIDs[k], nodeIDs[k]}] = k
+	}
+	child := func(tree, node int64) (int, error) {
+		k, ok := index[key{tree, node}]
+		if !ok {
+			return 0, fmt.Errorf("tree %d has no node %d", tree, node)
+		}
+		return k, nil
+	}
+	e.nodes = make([]onnxNode, n)
+	referenced := make([]bool, n)
+	var err error
+	for k := range e.nodes {
+		node := &e.nodes[k]
+		node.mode, node.feature, node.value = modes[k], int(featureIDs[k]), values[k]
+		node.missingTrue = missingTrue != nil && missingTrue[k] != 0
+		if node.feature < 0 {
+			return nil, errors.New("negative feature id")
+		}
+		switch node.mode {
+		case "LEAF":
+			continue
+		case "BRANCH_LEQ", "BRANCH_LT", "BRANCH_GTE", "BRANCH_GT", "BRANCH_EQ", "BRANCH_NEQ":
+		default:
+			return nil, fmt.Errorf("unsupported node mode %q", node.mode)
+		}
+		if node.yes, err = child(treeIDs[k], trueIDs[k]); err != nil {
+			return nil, err
+		}
+		if node.no, err = child(treeIDs[k], falseIDs[k]); err != nil {
+			return nil, err
+		}
+		referenced[node.yes], referenced[node.no] = true, true
+	}
+
+	weightTrees, weightNodes := attr[prefix+"treeids"].ints, attr[prefix+"nodeids"].ints
+	targets, weights := attr[prefix+"ids"].ints, attr[prefix+"weights"].floats
+	if len(weightNodes) != len(weightTrees) || len(targets) != len(weightTrees) || len(weights) != len(weightTrees) {
+		return nil, errors.New("leaf attributes differ in length")
+	}
+	for k := range weightTrees {
+		leaf, err := child(weightTrees[k], weightNodes[k])
+		if err != nil {
+			return nil, err
+		}
+		if targets[k] < 0 || targets[k] >= int64(e.Targets) {
+			return nil, fmt.Errorf("target %d out of range", targets[k])
+		}
+		e.nodes[leaf].weights = append(e.nodes[leaf].weights, onnxWeight{int(targets[k]), float64(weights[k])})
+	}
+	for k := range e.nodes {
+		if !referenced[k] {
+			e.roots = append(e.roots, k)
+		}
+	}
+	if len(e.roots) == 0 {
+		return nil, errors.New("no trees")
+	}
+	return e, nil
+}
+
+// leaf returns the leaf of the tree rooted at k reached by input.
+func (e *ONNXEnsemble) leaf(k int, input []float64) (*onnxNode, error) {
+	for depth := 0; ; depth++ {
+		node := &e.nodes[k]
+		if node.mode == "LEAF" {
+			return node, nil
+		}
+		if depth > len(e.nodes) {
+			return nil, corruptModel(errors.New("cyclic tree"))
+		}
+		if node.feature >= len(input) {
+			return nil, fmt.Errorf("%w: feature %d of %d", ErrSchemaMismatch, node.feature, len(input))
+		}
+		x := float32(input[node.feature])
+		var yes bool
+		if x != x && node.missingTrue {
+			yes = true
+		} else {
+			switch node.mode {
+			case "BRANCH_LEQ":
+				yes = x <= node.value
+			case "BRANCH_LT":
+				yes = x < node.value
+			case "BRANCH_GTE":
+				yes = x >= node.value
+			case "BRANCH_GT":
+				yes = x > node.value
+			case "BRANCH_EQ":
+				yes = x == node.value
+			case "BRANCH_NEQ":
+				yes = x != node.value
+			}
+		}
+		if yes {
+			k = node.yes
+		} else {
+			k = node.no
+		}
+	}
+}
+
+// Scores returns the aggregated and post-transformed score of every class
+// or target of input, like the "probabilities" or "variable" output of an
+// ONNX runtime.
+func (e *ONNXEnsemble) Scores(input []float64) ([]float64, error) {
+	scores := make([]float64, e.Targets)
+	seen := make([]bool, e.Targets)
+	for _, root := range e.roots {
+		leaf, err := e.leaf(root, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range leaf.weights {
+			switch {
+			case !seen[w.target] || e.Aggregate == "SUM" || e.Aggregate == "AVERAGE":
+				if !seen[w.target] && (e.Aggregate == "MIN" || e.Aggregate == "MAX") {
+					scores[w.target] = w.weight
+				} else {
+					scores[w.target] += w.weight
+				}
+			case e.Aggregate == "MIN":
+				scores[w.target] = math.Min(scores[w.target], w.weight)
+			case e.Aggregate == "MAX":
+				scores[w.target] = math.Max(scores[w.target], w.weight)
+			}
+			seen[w.target] = true
+		}
+	}
+	for t := range scores {
+		if e.Aggregate == "AVERAGE" {
+			scores[t] /= float64(len(e.roots))
+		}
+		if e.BaseValues != nil {
+			scores[t] += e.BaseValues[t]
+		}
+	}
+	switch e.PostTransform {
+	case "LOGISTIC":
+		for t, s := range scores {
+			scores[t] = 1 / (1 + math.Exp(-s))
+		}
+	case "SOFTMAX":
+		max := math.Inf(-1)
+		for _, s := range scores {
+			max = math.Max(max, s)
+		}
+		total := 0.0
+		for t, s := range scores {
+			scores[t] = math.Exp(s - max)
+			total += scores[t]
+		}
+		for t := range scores {
+			scores[t] /= total
+		}
+	}
+	return scores, nil
+}
+
+// Class returns the index of the highest score of input in the class
+// labels, the first on ties.
+func (e *ONNXEnsemble) Class(input []float64) (int, error) {
+	scores, err := e.Scores(input)
+	if err != nil {
+		return 0, err
+	}
+	best := 0
+	for t, s := range scores {
+		if s > scores[best] {
+			best = t
+		}
+	}
+	return best, nil
+}
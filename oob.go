@@ -0,0 +1,190 @@
+package randomForest
+
+import (
+	"math"
+	"slices"
+)
+
+// ClassificationOOB scores a classification forest on its out-of-bag rows.
+type ClassificationOOB struct {
+	Rows     int
+	Accuracy float64
+	LogLoss  float64
+}
+
+// RegressionOOB scores a regression forest on its out-of-bag rows.
+type RegressionOOB struct {
+	Rows int
+	MSE  float64
+	R2   float64
+}
+
+// ConfusionMatrix counts rows by actual label (row) and predicted label
+// (column), both indexed like Labels.
+type ConfusionMatrix[L Label] struct {
+	Labels []L
+	Counts [][]int
+}
+
+// oobRows returns the indices of the rows not drawn for a tree, offset by the
+// rows already evicted from the buffer.
+func (forest *BaseForest[F]) oobRows(used []bool) []int {
+	rows := make([]int, 0, len(used))
+	for i, ok := range used {
+		if !ok {
+			rows = append(rows, forest.evicted+i)
+		}
+	}
+	return rows
+}
+
+// oobIndex maps a row recorded by a tree back into Data; rows evicted since
+// the tree was built are reported as gone.
+func (forest *BaseForest[F]) oobIndex(row int) (int, bool) {
+	i := row - forest.evicted
+	return i, i >= 0 && i < len(forest.Data)
+}
+
+// OOBPredictions returns, for every row of the training buffer, the class
+// distribution voted by the trees that did not see that row. Rows that were
+// in every bag get a nil map. Trees from legacy JSON dumps or other libraries
+// know no out-of-bag rows.
+func (forest *ClassificationForest[F, L]) OOBPredictions() []map[L]float64 {
+	votes := make([]map[L]float64, len(forest.Data))
+	counts := make([]int, len(forest.Data))
+	for _, tree := range forest.Trees {
+		for _, row := range tree.oobRows {
+			i, ok := forest.oobIndex(row)
+			if !ok {
+				continue
+			}
+			if votes[i] == nil {
+				votes[i] = make(map[L]float64)
+			}
+			for l, v := range tree.Predicate(forest.Data[i]) {
+				votes[i][l] += v
+			}
+			counts[i]++
+		}
+	}
+	for i, v := range votes {
+		for l := range v {
+			v[l] /= float64(counts[i])
+		}
+	}
+	return votes
+}
+
+// OOBScore returns the accuracy and log-loss of the out-of-bag predictions.
+func (forest *ClassificationForest[F, L]) OOBScore() (ClassificationOOB, error) {
+	var score ClassificationOOB
+	for i, votes := range forest.OOBPredictions() {
+		if votes == nil {
+			continue
+		}
+		score.Rows++
+		if l, _ := maxLabel(votes); l == forest.Labels[i] {
+			score.Accuracy += 1
+		}
+		score.LogLoss -= math.Log(max(votes[forest.Labels[i]], 1e-15))
+	}
+	if score.Rows == 0 {
+		return score, ErrNoOOB
+	}
+	score.Accuracy /= float64(score.Rows)
+	score.LogLoss /= float64(score.Rows)
+	return score, nil
+}
+
+// OOBConfusion returns the confusion matrix of the out-of-bag predictions.
+func (forest *ClassificationForest[F, L]) OOBConfusion() (*ConfusionMatrix[L], error) {
+	predictions := forest.OOBPredictions()
+	actual := make([]L, 0, len(predictions))
+	predicted := make([]L, 0, len(predictions))
+	for i, votes := range predictions {
+		if votes == nil {
+			continue
+		}
+		l, _ := maxLabel(votes)
+		actual = append(actual, forest.Labels[i])
+		predicted = append(predicted, l)
+	}
+	if len(actual) == 0 {
+		return nil, ErrNoOOB
+	}
+	return NewConfusionMatrix(actual, predicted), nil
+}
+
+// NewConfusionMatrix counts the pairs of actual and predicted labels.
+func NewConfusionMatrix[L Label](actual, predicted []L) *ConfusionMatrix[L] {
+	index := make(map[L]int)
+	for _, l := range slices.Concat(actual, predicted) {
+		index[l] = 0
+	}
+	m := &ConfusionMatrix[L]{Labels: sortedKeys(index)}
+	for k, l := range m.Labels {
+		index[l] = k
+	}
+	m.Counts = make([][]int, len(m.Labels))
+	for k := range m.Counts {
+		m.Counts[k] = make([]int, len(m.Labels))
+	}
+	for i := range actual {
+		m.Counts[index[actual[i]]][index[predicted[i]]]++
+	}
+	return m
+}
+
+// OOBPredictions returns, for every row of the training buffer, the mean
+// prediction of the trees that did not see that row, or NaN for rows that
+// were in every bag.
+func (forest *RegressionForest[F]) OOBPredictions() []float64 {
+	sums := make([]float64, len(forest.Data))
+	counts := make([]int, len(forest.Data))
+	for _, tree := range forest.Trees {
+		for _, row := range tree.oobRows {
+			if i, ok := forest.oobIndex(row); ok {
+				sums[i] += tree.Predicate(forest.Data[i])
+				counts[i]++
+			}
+		}
+	}
+	for i := range sums {
+		if counts[i] == 0 {
+			sums[i] = math.NaN()
+		} else {
+			sums[i] /= float64(counts[i])
+		}
+	}
+	return sums
+}
+
+// OOBScore returns the mean squared error and R² of the out-of-bag
+// predictions.
+func (forest *RegressionForest[F]) OOBScore() (RegressionOOB, error) {
+	var score RegressionOOB
+	predictions := forest.OOBPredictions()
+	mean := 0.0
+	for i, p := range predictions {
+		if !math.IsNaN(p) {
+			score.Rows++
+			mean += forest.Labels[i]
+		}
+	}
+	if score.Rows == 0 {
+		return score, ErrNoOOB
+	}
+	mean /= float64(score.Rows)
+	variance := 0.0
+	for i, p := range predictions {
+		if !math.IsNaN(p) {
+			score.MSE += (p - forest.Labels[i]) * (p - forest.Labels[i])
+			variance += (mean - forest.Labels[i]) * (mean - forest.Labels[i])
+		}
+	}
+	score.MSE /= float64(score.Rows)
+	if variance > 0 {
+		score.R2 = 1 - score.MSE*float64(score.Rows)/variance
+	}
+	return score, nil
+}
@@ -0,0 +1,66 @@
+package randomForest
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOOBSaved(t *testing.T) {
+	x, y := loadIris(t)
+	forest := NewClassificationForest[float64, string](100, 20, 1, 0.5)
+	forest.MaxDepth = 10
+	forest.Seed = 1
+	if err := forest.TryTrain(x, y, 20); err != nil {
+		t.Fatal(err)
+	}
+	want := forest.OOBPredictions()
+	var buf bytes.Buffer
+	if err := forest.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &ClassificationForest[float64, string]{}
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loaded.OOBScore(); !errors.Is(err, ErrNoOOB) {
+		t.Fatalf("got %v without training rows, want %v", err, ErrNoOOB)
+	}
+	loaded.Data, loaded.Labels = forest.Data, forest.Labels
+	if got := loaded.OOBPredictions(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v after loading, want %v", got, want)
+	}
+}
+
+func TestRegressionOOBSaved(t *testing.T) {
+	x, _ := loadIris(t)
+	labels := make([]float64, len(x))
+	for i, row := range x {
+		labels[i] = row[0]
+		x[i] = row[1:]
+	}
+	forest := NewRegressionForest[float64](1000, 20, 1, 1)
+	forest.Seed = 1
+	if err := forest.TryTrain(x, labels, 20); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := forest.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &RegressionForest[float64]{}
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for i := range forest.Trees {
+		if !reflect.DeepEqual(loaded.Trees[i].oobRows, forest.Trees[i].oobRows) {
+			t.Fatalf("tree %d: out-of-bag rows not restored", i)
+		}
+	}
+	loaded.Data, loaded.Labels = forest.Data, forest.Labels
+	want, _ := forest.OOBScore()
+	if got, err := loaded.OOBScore(); err != nil || got != want {
+		t.Fatalf("got %+v, %v after loading, want %+v", got, err, want)
+	}
+}
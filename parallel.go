@@ -0,0 +1,138 @@
+package randomForest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+type builtTree interface {
+	oob() float64
+	Nodes() int
+}
+
+// buildTrees calls build for every tree index on NUM_CPU workers and returns
+// the trees indexed by tree number. If a tree fails or ctx is done, no new
+// trees are scheduled and it returns the trees finished so far, in tree
+// order, and the first error.
+func buildTrees[T builtTree](ctx context.Context, progress ProgressReporter, treesAmount int, build func(ctx context.Context, x int) (T, error)) ([]T, error) {
+	trees := make([]T, treesAmount)
+	built := make([]bool, treesAmount)
+	jobs := make(chan int)
+
+	var (
+		mutex    sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	for w := 0; w < min(max(NUM_CPU, 1), treesAmount); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for x := range jobs {
+				mutex.Lock()
+				progress.TreeStarted(x, treesAmount)
+				mutex.Unlock()
+				tree, err := build(ctx, x)
+				mutex.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+				} else {
+					trees[x] = tree
+					built[x] = true
+					progress.TreeFinished(x, treesAmount, tree.oob(), tree.Nodes())
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+schedule:
+	for x := 0; x < treesAmount; x++ {
+		mutex.Lock()
+		failed := firstErr != nil
+		mutex.Unlock()
+		if failed {
+			break
+		}
+		select {
+		case jobs <- x:
+		case <-ctx.Done():
+			mutex.Lock()
+			if firstErr == nil {
+				firstErr = ctx.Err()
+			}
+			mutex.Unlock()
+			break schedule
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	count := 0
+	for _, ok := range built {
+		if ok {
+			count++
+		}
+	}
+	progress.TrainingDone(count, firstErr)
+	if firstErr != nil {
+		finished := make([]T, 0, count)
+		for x, ok := range built {
+			if ok {
+				finished = append(finished, trees[x])
+			}
+		}
+		return finished, firstErr
+	}
+	return trees, nil
+}
+
+// batchChunk is the number of rows a prediction worker takes at a time.
+const batchChunk = 256
+
+// predictBatch fills out[i] with predict(inputs[i]), or fails if out does
+// not have one entry per input.
+func predictBatch[F Feature, T any](inputs [][]F, out []T, predict func(input []F) T) error {
+	if len(out) != len(inputs) {
+		return fmt.Errorf("%w: %d inputs, %d outputs", ErrOutputSize, len(inputs), len(out))
+	}
+	runBatch(inputs, out, predict)
+	return nil
+}
+
+// predictAll runs runBatch into a new slice.
+func predictAll[F Feature, T any](inputs [][]F, predict func(input []F) T) []T {
+	out := make([]T, len(inputs))
+	runBatch(inputs, out, predict)
+	return out
+}
+
+// runBatch fills out[i] with predict(inputs[i]) on NUM_CPU workers that take
+// chunks of batchChunk rows. out must be as long as inputs.
+func runBatch[F Feature, T any](inputs [][]F, out []T, predict func(input []F) T) {
+	var (
+		next atomic.Int64
+		wg   sync.WaitGroup
+	)
+	chunks := (len(inputs) + batchChunk - 1) / batchChunk
+	for w := 0; w < min(max(NUM_CPU, 1), chunks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				start := int(next.Add(batchChunk)) - batchChunk
+				if start >= len(inputs) {
+					return
+				}
+				for i := start; i < min(start+batchChunk, len(inputs)); i++ {
+					out[i] = predict(inputs[i])
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,229 @@
+package randomForest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type fakeTree struct {
+	x int
+}
+
+func (t *fakeTree) oob() float64 { return 0 }
+func (t *fakeTree) Nodes() int   { return 1 }
+
+func withCPUs(t testing.TB, n int) {
+	old := NUM_CPU
+	NUM_CPU = n
+	t.Cleanup(func() { NUM_CPU = old })
+}
+
+func TestBuildTreesIndexed(t *testing.T) {
+	withCPUs(t, 8)
+	trees, err := buildTrees(context.Background(), NopProgress{}, 100, func(ctx context.Context, x int) (*fakeTree, error) {
+		time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+		return &fakeTree{x: x}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trees) != 100 {
+		t.Fatalf("got %d trees, want 100", len(trees))
+	}
+	for x, tree := range trees {
+		if tree == nil || tree.x != x {
+			t.Fatalf("tree %d holds %v", x, tree)
+		}
+	}
+}
+
+func TestBuildTreesError(t *testing.T) {
+	withCPUs(t, 8)
+	failure := errors.New("boom")
+	trees, err := buildTrees(context.Background(), NopProgress{}, 100, func(ctx context.Context, x int) (*fakeTree, error) {
+		if x == 37 {
+			return nil, failure
+		}
+		return &fakeTree{x: x}, nil
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("got %v, want %v", err, failure)
+	}
+	if len(trees) == 0 || len(trees) >= 100 {
+		t.Fatalf("got %d trees with an error, want the finished ones", len(trees))
+	}
+	for k, tree := range trees {
+		if tree == nil || tree.x == 37 || (k > 0 && tree.x <= trees[k-1].x) {
+			t.Fatalf("tree %d holds %v", k, tree)
+		}
+	}
+}
+
+func TestTrainRaceClassification(t *testing.T) {
+	withCPUs(t, 8)
+	x, y := loadIris(t)
+	forest := NewClassificationForest[float64, string](1000, 50, 1, 0.5)
+	forest.MaxDepth = 10
+	forest.Seed = 1
+	if err := forest.TryTrain(x, y, 50); err != nil {
+		t.Fatal(err)
+	}
+	if len(forest.Trees) != 50 {
+		t.Fatalf("got %d trees, want 50", len(forest.Trees))
+	}
+	serial := NewClassificationForest[float64, string](1000, 50, 1, 0.5)
+	serial.MaxDepth = 10
+	serial.Seed = 1
+	NUM_CPU = 1
+	if err := serial.TryTrain(x, y, 50); err != nil {
+		t.Fatal(err)
+	}
+	for i := range forest.Trees {
+		if !reflect.DeepEqual(forest.Trees[i], serial.Trees[i]) {
+			t.Fatalf("tree %d differs between 8 workers and 1", i)
+		}
+	}
+}
+
+func TestTrainRaceRegression(t *testing.T) {
+	withCPUs(t, 8)
+	x, _ := loadIris(t)
+	labels := make([]float64, len(x))
+	for i, row := range x {
+		labels[i] = row[0]
+		x[i] = row[1:]
+	}
+	forest := NewRegressionForest[float64](1000, 50, 1, 1)
+	forest.Seed = 1
+	if err := forest.TryTrain(x, labels, 50); err != nil {
+		t.Fatal(err)
+	}
+	serial := NewRegressionForest[float64](1000, 50, 1, 1)
+	serial.Seed = 1
+	NUM_CPU = 1
+	if err := serial.TryTrain(x, labels, 50); err != nil {
+		t.Fatal(err)
+	}
+	if len(forest.Trees) != 50 || len(serial.Trees) != 50 {
+		t.Fatalf("got %d and %d trees, want 50", len(forest.Trees), len(serial.Trees))
+	}
+	for i := range forest.Trees {
+		if !reflect.DeepEqual(forest.Trees[i], serial.Trees[i]) {
+			t.Fatalf("tree %d differs between 8 workers and 1", i)
+		}
+	}
+}
+
+// mongoDatabase connects to the server in RF_MONGO_URI and fills a fresh
+// steps_<game> collection with iris rows, or skips the test.
+func mongoDatabase(t *testing.T, game string, docs func(row []float64, label string) any) *mongo.Database {
+	uri := os.Getenv("RF_MONGO_URI")
+	if uri == "" {
+		t.Skip("RF_MONGO_URI not set")
+	}
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(ctx) })
+	db := client.Database(fmt.Sprintf("rf_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() { db.Drop(ctx) })
+	x, y := loadIris(t)
+	var batch []any
+	for i := range x {
+		batch = append(batch, docs(x[i], y[i]))
+	}
+	if _, err := db.Collection("steps_"+game).InsertMany(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestTrainRaceMongoClassification(t *testing.T) {
+	withCPUs(t, 8)
+	db := mongoDatabase(t, "iris", func(row []float64, label string) any {
+		return bson.M{"input": append(append([]float64{}, row...), 0), "label": label}
+	})
+	forest := NewMongoClassForest[float64, string](db, 20, 100, 2, 4, 3, "iris")
+	forest.Seed = 1
+	if err := forest.TryTrain(); err != nil {
+		t.Fatal(err)
+	}
+	if len(forest.Trees) != 20 {
+		t.Fatalf("got %d trees, want 20", len(forest.Trees))
+	}
+	for i, tree := range forest.Trees {
+		if tree == nil || tree.Root == nil {
+			t.Fatalf("tree %d missing", i)
+		}
+	}
+}
+
+func TestTrainRaceMongoRegression(t *testing.T) {
+	withCPUs(t, 8)
+	db := mongoDatabase(t, "iris", func(row []float64, label string) any {
+		return bson.M{"input": row[1:], "reward": row[0]}
+	})
+	forest := NewMongoForest[float64](db, 20, 100, 2, 3, 5, "iris")
+	forest.Seed = 1
+	if err := forest.TryTrain(); err != nil {
+		t.Fatal(err)
+	}
+	if len(forest.Trees) != 20 {
+		t.Fatalf("got %d trees, want 20", len(forest.Trees))
+	}
+	for i, tree := range forest.Trees {
+		if tree == nil || tree.Root == nil {
+			t.Fatalf("tree %d missing", i)
+		}
+	}
+}
+
+// cancelAfter cancels training once n trees have finished.
+type cancelAfter struct {
+	NopProgress
+	n        int
+	finished int
+	cancel   context.CancelFunc
+}
+
+func (p *cancelAfter) TreeFinished(tree, total int, oob float64, nodes int) {
+	p.finished++
+	if p.finished == p.n {
+		p.cancel()
+	}
+}
+
+func TestTrainCancelledKeepsTrees(t *testing.T) {
+	x, y := loadIris(t)
+	forest := NewClassificationForest[float64, string](1000, 50, 1, 0.5)
+	forest.MaxDepth = 10
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	progress := &cancelAfter{n: 5, cancel: cancel}
+	forest.Progress = progress
+	err := forest.TrainContext(ctx, x, y, 50)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+	if len(forest.Trees) < 5 || len(forest.Trees) != progress.finished {
+		t.Fatalf("kept %d trees after %d finished, want at least 5", len(forest.Trees), progress.finished)
+	}
+	if len(forest.Data) != len(x) {
+		t.Fatalf("got %d rows after cancelling, want the %d inputs", len(forest.Data), len(x))
+	}
+	if acc := accuracy(forest, x, y); acc < 0.9 {
+		t.Fatalf("accuracy %.3f of the partial forest", acc)
+	}
+}
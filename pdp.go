@@ -0,0 +1,218 @@
+package randomForest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// Regressor is implemented by RegressionForest and MongoForest.
+type Regressor[F Feature] interface {
+	Predicate(input []F) float64
+}
+
+// Classifier is implemented by ClassificationForest and MongoClassForest.
+type Classifier[F Feature, L Label] interface {
+	PredicateWithData(input []F) map[L]float64
+}
+
+// Curve is the partial dependence of a prediction on one column: ICE holds
+// one curve per data row, Mean their average over the rows.
+type Curve[F Feature] struct {
+	Column int
+	Grid   []F
+	Mean   []float64
+	ICE    [][]float64
+}
+
+// Surface is the partial dependence of a prediction on two columns, indexed
+// Mean[i][j] for GridX[i] and GridY[j].
+type Surface[F Feature] struct {
+	ColumnX int
+	ColumnY int
+	GridX   []F
+	GridY   []F
+	Mean    [][]float64
+}
+
+// Grid returns up to points distinct values of column taken at evenly
+// spaced quantiles of data, or every distinct value if there are fewer.
+func Grid[F Feature](data [][]F, column int, points int) []F {
+	values := make([]F, len(data))
+	for i, row := range data {
+		values[i] = row[column]
+	}
+	slices.Sort(values)
+	values = slices.Compact(values)
+	if points <= 0 || len(values) <= points {
+		return values
+	}
+	grid := make([]F, 0, points)
+	for k := 0; k < points; k++ {
+		grid = append(grid, values[k*(len(values)-1)/max(points-1, 1)])
+	}
+	return slices.Compact(grid)
+}
+
+// iceRows calls predict on every data row with column replaced by each grid
+// value in turn.
+func iceRows[F Feature](data [][]F, columns []int, grid [][]F, predict func(row []F, r int, cell []int)) {
+	for r, input := range data {
+		row := slices.Clone(input)
+		cell := make([]int, len(columns))
+		var walk func(k int)
+		walk = func(k int) {
+			if k == len(columns) {
+				predict(row, r, cell)
+				return
+			}
+			for i, v := range grid[k] {
+				row[columns[k]] = v
+				cell[k] = i
+				walk(k + 1)
+			}
+		}
+		walk(0)
+	}
+}
+
+func newCurve[F Feature](column int, grid []F, rows int) *Curve[F] {
+	c := &Curve[F]{Column: column, Grid: grid, Mean: make([]float64, len(grid)), ICE: make([][]float64, rows)}
+	for r := range c.ICE {
+		c.ICE[r] = make([]float64, len(grid))
+	}
+	return c
+}
+
+func (c *Curve[F]) average() {
+	for _, ice := range c.ICE {
+		for i, v := range ice {
+			c.Mean[i] += v / float64(len(c.ICE))
+		}
+	}
+}
+
+func newSurface[F Feature](x, y int, gridX, gridY []F) *Surface[F] {
+	s := &Surface[F]{ColumnX: x, ColumnY: y, GridX: gridX, GridY: gridY, Mean: make([][]float64, len(gridX))}
+	for i := range s.Mean {
+		s.Mean[i] = make([]float64, len(gridY))
+	}
+	return s
+}
+
+// PartialDependence computes the ICE curves of forest over grid for column
+// and their average.
+func PartialDependence[F Feature](forest Regressor[F], data [][]F, column int, grid []F) *Curve[F] {
+	c := newCurve(column, grid, len(data))
+	iceRows(data, []int{column}, [][]F{grid}, func(row []F, r int, cell []int) {
+		c.ICE[r][cell[0]] = forest.Predicate(row)
+	})
+	c.average()
+	return c
+}
+
+// PartialDependence2D computes the average prediction of forest over the
+// grid of two columns.
+func PartialDependence2D[F Feature](forest Regressor[F], data [][]F, x, y int, gridX, gridY []F) *Surface[F] {
+	s := newSurface(x, y, gridX, gridY)
+	iceRows(data, []int{x, y}, [][]F{gridX, gridY}, func(row []F, r int, cell []int) {
+		s.Mean[cell[0]][cell[1]] += forest.Predicate(row) / float64(len(data))
+	})
+	return s
+}
+
+// ClassPartialDependence computes the ICE curves of the class probabilities
+// of forest over grid for column, one Curve per class.
+func ClassPartialDependence[F Feature, L Label](forest Classifier[F, L], data [][]F, column int, grid []F) map[L]*Curve[F] {
+	curves := make(map[L]*Curve[F])
+	iceRows(data, []int{column}, [][]F{grid}, func(row []F, r int, cell []int) {
+		for l, p := range probabilities(forest.PredicateWithData(row)) {
+			if curves[l] == nil {
+				curves[l] = newCurve(column, grid, len(data))
+			}
+			curves[l].ICE[r][cell[0]] = p
+		}
+	})
+	for _, c := range curves {
+		c.average()
+	}
+	return curves
+}
+
+// ClassPartialDependence2D computes the average class probabilities of
+// forest over the grid of two columns, one Surface per class.
+func ClassPartialDependence2D[F Feature, L Label](forest Classifier[F, L], data [][]F, x, y int, gridX, gridY []F) map[L]*Surface[F] {
+	surfaces := make(map[L]*Surface[F])
+	iceRows(data, []int{x, y}, [][]F{gridX, gridY}, func(row []F, r int, cell []int) {
+		for l, p := range probabilities(forest.PredicateWithData(row)) {
+			if surfaces[l] == nil {
+				surfaces[l] = newSurface(x, y, gridX, gridY)
+			}
+			surfaces[l].Mean[cell[0]][cell[1]] += p / float64(len(data))
+		}
+	})
+	return surfaces
+}
+
+// probabilities scales votes to sum to 1.
+func probabilities[L Label](votes map[L]float64) map[L]float64 {
+	total := 0.0
+	for _, v := range votes {
+		total += v
+	}
+	result := make(map[L]float64, len(votes))
+	for l, v := range votes {
+		if total > 0 {
+			result[l] = v / total
+		}
+	}
+	return result
+}
+
+// WriteCSV writes one line per grid value: the value, the partial dependence
+// and the ICE value of every row.
+func (c *Curve[F]) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	header := []string{"value", "pd"}
+	for r := range c.ICE {
+		header = append(header, "ice_"+strconv.Itoa(r))
+	}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for i, v := range c.Grid {
+		line := []string{fmt.Sprint(v), formatFloat(c.Mean[i])}
+		for _, ice := range c.ICE {
+			line = append(line, formatFloat(ice[i]))
+		}
+		if err := out.Write(line); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteCSV writes one line per grid cell: the two values and the partial
+// dependence.
+func (s *Surface[F]) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"x", "y", "pd"}); err != nil {
+		return err
+	}
+	for i, x := range s.GridX {
+		for j, y := range s.GridY {
+			if err := out.Write([]string{fmt.Sprint(x), fmt.Sprint(y), formatFloat(s.Mean[i][j])}); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,112 @@
+package randomForest
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// monotoneForest fits 3·x0 next to a noise column and a column that is
+// constant during training.
+func monotoneForest(t *testing.T) (*RegressionForest[float64], [][]float64) {
+	x, _, y := signalAndNoise(300, 1)
+	for i := range x {
+		x[i] = append(x[i], 1)
+	}
+	forest := NewRegressionForest[float64](1000, 20, 1, 1)
+	forest.Seed = 1
+	if err := forest.TryTrain(x, y, 20); err != nil {
+		t.Fatal(err)
+	}
+	return forest, x[:50]
+}
+
+func TestPartialDependenceMonotone(t *testing.T) {
+	forest, data := monotoneForest(t)
+	curve := PartialDependence[float64](forest, data, 0, Grid(data, 0, 20))
+	for i := 1; i < len(curve.Mean); i++ {
+		if curve.Mean[i] < curve.Mean[i-1] {
+			t.Fatalf("partial dependence falls from %v at %v to %v at %v", curve.Mean[i-1], curve.Grid[i-1], curve.Mean[i], curve.Grid[i])
+		}
+	}
+	if rise := curve.Mean[len(curve.Mean)-1] - curve.Mean[0]; rise < 2 {
+		t.Fatalf("partial dependence rises by %v over the grid, want most of 3", rise)
+	}
+}
+
+func TestPartialDependenceFlatWithoutSplits(t *testing.T) {
+	forest, data := monotoneForest(t)
+	if mdi := forest.MeanDecreaseImpurity(); mdi[2] != 0 {
+		t.Fatalf("the constant column was split on: %v", mdi)
+	}
+	curve := PartialDependence[float64](forest, data, 2, []float64{-1, 0, 1, 2})
+	for r, ice := range curve.ICE {
+		for i := range ice {
+			if ice[i] != ice[0] {
+				t.Fatalf("row %d: ICE %v is not flat", r, ice)
+			}
+		}
+	}
+	for i := range curve.Mean {
+		if math.Abs(curve.Mean[i]-curve.Mean[0]) > 1e-12 {
+			t.Fatalf("partial dependence %v is not flat", curve.Mean)
+		}
+	}
+}
+
+func TestICEAveragesToPartialDependence(t *testing.T) {
+	forest, data := monotoneForest(t)
+	x, y := loadIris(t)
+	classifier := trainedIris(t, x, y)
+	var rows [][]float64
+	for i := 0; i < len(x); i += 5 {
+		rows = append(rows, x[i])
+	}
+	curves := []*Curve[float64]{PartialDependence[float64](forest, data, 1, Grid(data, 1, 10))}
+	for _, c := range ClassPartialDependence[float64, string](classifier, rows, 2, Grid(x, 2, 10)) {
+		curves = append(curves, c)
+	}
+	for _, c := range curves {
+		for i := range c.Grid {
+			mean := 0.0
+			for _, ice := range c.ICE {
+				mean += ice[i]
+			}
+			mean /= float64(len(c.ICE))
+			if math.Abs(mean-c.Mean[i]) > 1e-12 {
+				t.Fatalf("column %d at %v: ICE average %v, partial dependence %v", c.Column, c.Grid[i], mean, c.Mean[i])
+			}
+		}
+	}
+}
+
+func TestPartialDependenceCSV(t *testing.T) {
+	curve := &Curve[float64]{
+		Column: 0,
+		Grid:   []float64{1, 2.5},
+		Mean:   []float64{0.5, 0.25},
+		ICE:    [][]float64{{0, 0.5}, {1, 0}},
+	}
+	var buf bytes.Buffer
+	if err := curve.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "value,pd,ice_0,ice_1\n1,0.5,0,1\n2.5,0.25,0.5,0\n"; got != want {
+		t.Fatalf("curve CSV:\n%s\nwant:\n%s", got, want)
+	}
+
+	surface := &Surface[float64]{
+		ColumnX: 0,
+		ColumnY: 1,
+		GridX:   []float64{1, 2},
+		GridY:   []float64{3, 4},
+		Mean:    [][]float64{{0.5, 0.125}, {1, 0}},
+	}
+	buf.Reset()
+	if err := surface.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "x,y,pd\n1,3,0.5\n1,4,0.125\n2,3,1\n2,4,0\n"; got != want {
+		t.Fatalf("surface CSV:\n%s\nwant:\n%s", got, want)
+	}
+}
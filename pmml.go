@@ -0,0 +1,791 @@
+package randomForest
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const pmmlNamespace = "http://www.dmg.org/PMML-4_4"
+
+type pmmlDocument struct {
+	XMLName        xml.Name `xml:"PMML"`
+	Xmlns          string   `xml:"xmlns,attr,omitempty"`
+	Version        string   `xml:"version,attr"`
+	Header         pmmlHeader
+	DataDictionary pmmlDataDictionary
+	MiningModel    *pmmlMiningModel `xml:",omitempty"`
+	TreeModel      *pmmlTreeModel   `xml:",omitempty"`
+}
+
+type pmmlHeader struct {
+	Application struct {
+		Name string `xml:"name,attr"`
+	}
+}
+
+type pmmlDataDictionary struct {
+	NumberOfFields int             `xml:"numberOfFields,attr"`
+	Fields         []pmmlDataField `xml:"DataField"`
+}
+
+type pmmlDataField struct {
+	Name     string      `xml:"name,attr"`
+	Optype   string      `xml:"optype,attr"`
+	DataType string      `xml:"dataType,attr"`
+	Values   []pmmlValue `xml:"Value"`
+}
+
+type pmmlValue struct {
+	Value    string `xml:"value,attr"`
+	Property string `xml:"property,attr,omitempty"`
+}
+
+type pmmlMiningSchema struct {
+	Fields []pmmlMiningField `xml:"MiningField"`
+}
+
+type pmmlMiningField struct {
+	Name      string `xml:"name,attr"`
+	UsageType string `xml:"usageType,attr,omitempty"`
+}
+
+type pmmlMiningModel struct {
+	FunctionName string `xml:"functionName,attr"`
+	MiningSchema pmmlMiningSchema
+	Segmentation *pmmlSegmentation
+}
+
+type pmmlSegmentation struct {
+	MultipleModelMethod string        `xml:"multipleModelMethod,attr"`
+	Segments            []pmmlSegment `xml:"Segment"`
+}
+
+type pmmlSegment struct {
+	ID        string          `xml:"id,attr,omitempty"`
+	Predicate []pmmlPredicate `xml:",any"`
+	TreeModel *pmmlTreeModel
+}
+
+type pmmlTreeModel struct {
+	FunctionName         string `xml:"functionName,attr"`
+	MissingValueStrategy string `xml:"missingValueStrategy,attr,omitempty"`
+	NoTrueChildStrategy  string `xml:"noTrueChildStrategy,attr,omitempty"`
+	SplitCharacteristic  string `xml:"splitCharacteristic,attr,omitempty"`
+	MiningSchema         pmmlMiningSchema
+	Node                 pmmlNode
+}
+
+// pmmlNode is a tree node. Its predicate is the first element of Predicate;
+// unknown elements such as Extension are collected there too.
+type pmmlNode struct {
+	ID                 string                  `xml:"id,attr,omitempty"`
+	Score              string                  `xml:"score,attr,omitempty"`
+	RecordCount        float64                 `xml:"recordCount,attr,omitempty"`
+	DefaultChild       string                  `xml:"defaultChild,attr,omitempty"`
+	Predicate          []pmmlPredicate         `xml:",any"`
+	ScoreDistributions []pmmlScoreDistribution `xml:"ScoreDistribution"`
+	Nodes              []pmmlNode              `xml:"Node"`
+}
+
+type pmmlScoreDistribution struct {
+	Value       string   `xml:"value,attr"`
+	RecordCount float64  `xml:"recordCount,attr"`
+	Probability *float64 `xml:"probability,attr,omitempty"`
+}
+
+// pmmlPredicate is any predicate element, named by XMLName: True, False,
+// SimplePredicate, SimpleSetPredicate or CompoundPredicate.
+type pmmlPredicate struct {
+	XMLName         xml.Name
+	Field           string          `xml:"field,attr,omitempty"`
+	Operator        string          `xml:"operator,attr,omitempty"`
+	Value           *string         `xml:"value,attr,omitempty"`
+	BooleanOperator string          `xml:"booleanOperator,attr,omitempty"`
+	Array           *pmmlArray      `xml:"Array,omitempty"`
+	Predicates      []pmmlPredicate `xml:",any"`
+}
+
+type pmmlArray struct {
+	N      int    `xml:"n,attr"`
+	Type   string `xml:"type,attr"`
+	Values string `xml:",chardata"`
+}
+
+func pmmlElement(name string) xml.Name {
+	return xml.Name{Local: name}
+}
+
+// pmmlDataType returns the PMML data type of a feature or label type.
+func pmmlDataType[T Feature]() (dataType, arrayType string) {
+	switch reflect.TypeFor[T]().Kind() {
+	case reflect.Float64:
+		return "double", "real"
+	case reflect.String:
+		return "string", "string"
+	default:
+		return "integer", "int"
+	}
+}
+
+func formatFeature[T Feature](v T) string {
+	r := reflect.ValueOf(v)
+	switch r.Kind() {
+	case reflect.Float64:
+		return formatFloat(r.Float())
+	case reflect.String:
+		return r.String()
+	default:
+		return strconv.FormatInt(r.Int(), 10)
+	}
+}
+
+func parseFeature[T Feature](s string) (T, error) {
+	var v T
+	r := reflect.ValueOf(&v).Elem()
+	switch r.Kind() {
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return v, err
+		}
+		r.SetFloat(f)
+	case reflect.String:
+		r.SetString(s)
+	default:
+		i, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return v, err
+		}
+		r.SetInt(i)
+	}
+	return v, nil
+}
+
+// formatArray writes values as a PMML Array, quoting strings.
+func formatArray[T Feature](values []T) *pmmlArray {
+	_, arrayType := pmmlDataType[T]()
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = formatFeature(v)
+		if arrayType == "string" {
+			parts[i] = `"` + strings.ReplaceAll(strings.ReplaceAll(parts[i], `\`, `\\`), `"`, `\"`) + `"`
+		}
+	}
+	return &pmmlArray{N: len(values), Type: arrayType, Values: strings.Join(parts, " ")}
+}
+
+// parseArray splits the content of a PMML Array into its values.
+func parseArray(a *pmmlArray) ([]string, error) {
+	var values []string
+	s := a.Values
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			break
+		}
+		if s[0] != '"' {
+			end := strings.IndexAny(s, " \t\r\n")
+			if end < 0 {
+				end = len(s)
+			}
+			values = append(values, s[:end])
+			s = s[end:]
+			continue
+		}
+		var b strings.Builder
+		k := 1
+		for ; k < len(s) && s[k] != '"'; k++ {
+			if s[k] == '\\' && k+1 < len(s) {
+				k++
+			}
+			b.WriteByte(s[k])
+		}
+		if k == len(s) {
+			return nil, errors.New("unterminated string in array")
+		}
+		values = append(values, b.String())
+		s = s[k+1:]
+	}
+	if a.N != 0 && a.N != len(values) {
+		return nil, fmt.Errorf("array has %d values, want %d", len(values), a.N)
+	}
+	return values, nil
+}
+
+func pmmlFieldName(c int) string {
+	return "x" + strconv.Itoa(c)
+}
+
+const pmmlTarget = "y"
+
+// pmmlTest returns the predicate sending a row left at a split, and the
+// predicate with the opposite result if reverse is set.
+func pmmlTest[F Feature](column int, columnType ColumnType, value *F, categories []F, reverse bool) pmmlPredicate {
+	field := pmmlFieldName(column)
+	if columnType == NUMERIC {
+		operator := "lessOrEqual"
+		if reverse {
+			operator = "greaterThan"
+		}
+		v := formatFeature(*value)
+		return pmmlPredicate{XMLName: pmmlElement("SimplePredicate"), Field: field, Operator: operator, Value: &v}
+	}
+	if categories == nil {
+		categories = []F{*value}
+	}
+	if len(categories) == 1 {
+		operator := "equal"
+		if reverse {
+			operator = "notEqual"
+		}
+		v := formatFeature(categories[0])
+		return pmmlPredicate{XMLName: pmmlElement("SimplePredicate"), Field: field, Operator: operator, Value: &v}
+	}
+	operator := "isIn"
+	if reverse {
+		operator = "isNotIn"
+	}
+	return pmmlPredicate{XMLName: pmmlElement("SimpleSetPredicate"), Field: field, BooleanOperator: operator, Array: formatArray(categories)}
+}
+
+// pmmlSplit returns the predicate of the left child of a split. Surrogates
+// become a surrogate CompoundPredicate that ends in the learned default.
+func pmmlSplit[F Feature](column int, columnType ColumnType, value *F, categories []F, surrogates []Surrogate[F], missingLeft bool) pmmlPredicate {
+	if columnType == AUTO && value != nil {
+		columnType = inferColumnType(*value)
+	}
+	test := pmmlTest(column, columnType, value, categories, false)
+	if len(surrogates) == 0 {
+		return test
+	}
+	compound := pmmlPredicate{XMLName: pmmlElement("CompoundPredicate"), BooleanOperator: "surrogate", Predicates: []pmmlPredicate{test}}
+	for _, s := range surrogates {
+		surrogateType := s.Type
+		if surrogateType == AUTO && s.Value != nil {
+			surrogateType = inferColumnType(*s.Value)
+		}
+		compound.Predicates = append(compound.Predicates, pmmlTest(s.Column, surrogateType, s.Value, s.Categories, s.Reverse))
+	}
+	if missingLeft {
+		compound.Predicates = append(compound.Predicates, pmmlPredicate{XMLName: pmmlElement("True")})
+	} else {
+		compound.Predicates = append(compound.Predicates, pmmlPredicate{XMLName: pmmlElement("False")})
+	}
+	return compound
+}
+
+// pmmlBranch fills in the children of a split node: the left child carries
+// the split predicate, the right one is taken otherwise, and rows missing
+// the split column go to the default child.
+func pmmlBranch(node, left, right *pmmlNode, test pmmlPredicate, missingLeft bool) {
+	left.Predicate = []pmmlPredicate{test}
+	right.Predicate = []pmmlPredicate{{XMLName: pmmlElement("True")}}
+	node.DefaultChild = right.ID
+	if missingLeft {
+		node.DefaultChild = left.ID
+	}
+	node.Nodes = []pmmlNode{*left, *right}
+}
+
+func exportPMMLClassificationNode[F Feature, L Label](node *ClassificationNode[F, L], next *int) pmmlNode {
+	n := pmmlNode{ID: strconv.Itoa(*next), RecordCount: float64(node.Size)}
+	*next++
+	if node.isLeaf() || node.Left == nil || node.Right == nil {
+		labels := node.leafLabels()
+		total := 0.0
+		for _, v := range labels {
+			total += v
+		}
+		max := 0.0
+		for _, l := range sortedKeys(labels) {
+			p := labels[l] / total
+			if p > max {
+				max = p
+				n.Score = formatFeature(l)
+			}
+			n.ScoreDistributions = append(n.ScoreDistributions, pmmlScoreDistribution{Value: formatFeature(l), RecordCount: labels[l], Probability: &p})
+		}
+		return n
+	}
+	left := exportPMMLClassificationNode(node.Left, next)
+	right := exportPMMLClassificationNode(node.Right, next)
+	pmmlBranch(&n, &left, &right, pmmlSplit(node.Column, node.Type, node.Value, node.Categories, node.Surrogates, node.MissingLeft), node.MissingLeft)
+	return n
+}
+
+func exportPMMLRegressionNode[F Feature](node *RegressionNode[F], next *int) pmmlNode {
+	n := pmmlNode{ID: strconv.Itoa(*next), RecordCount: float64(node.Size)}
+	*next++
+	if node.isLeaf() || node.Left == nil || node.Right == nil {
+		n.Score = formatFloat(node.leafLabel())
+		return n
+	}
+	left := exportPMMLRegressionNode(node.Left, next)
+	right := exportPMMLRegressionNode(node.Right, next)
+	pmmlBranch(&n, &left, &right, pmmlSplit(node.Column, node.Type, node.Value, node.Categories, node.Surrogates, node.MissingLeft), node.MissingLeft)
+	return n
+}
+
+// pmmlDictionary describes the feature columns. Missing markers become
+// Values with property "missing", so PMML consumers treat them like absent
+// values.
+func pmmlDictionary[F Feature](base *BaseForest[F]) ([]pmmlDataField, pmmlMiningSchema) {
+	dataType, _ := pmmlDataType[F]()
+	var zero F
+	fields := make([]pmmlDataField, base.Features)
+	schema := pmmlMiningSchema{Fields: make([]pmmlMiningField, 0, base.Features+1)}
+	for c := range fields {
+		fields[c] = pmmlDataField{Name: pmmlFieldName(c), Optype: "continuous", DataType: dataType}
+		if resolveColumnType(base.Schema, c, zero) != NUMERIC {
+			fields[c].Optype = "categorical"
+		}
+		for _, m := range base.Missing {
+			if m.Column == c {
+				fields[c].Values = append(fields[c].Values, pmmlValue{Value: formatFeature(m.Value), Property: "missing"})
+			}
+		}
+		schema.Fields = append(schema.Fields, pmmlMiningField{Name: fields[c].Name})
+	}
+	schema.Fields = append(schema.Fields, pmmlMiningField{Name: pmmlTarget, UsageType: "target"})
+	return fields, schema
+}
+
+func writePMML(w io.Writer, functionName string, fields []pmmlDataField, schema pmmlMiningSchema, trees []pmmlNode) error {
+	doc := pmmlDocument{Xmlns: pmmlNamespace, Version: "4.4"}
+	doc.Header.Application.Name = "randomForest"
+	doc.DataDictionary = pmmlDataDictionary{NumberOfFields: len(fields), Fields: fields}
+	model := &pmmlMiningModel{FunctionName: functionName, MiningSchema: schema, Segmentation: &pmmlSegmentation{MultipleModelMethod: "average"}}
+	for t, root := range trees {
+		root.Predicate = []pmmlPredicate{{XMLName: pmmlElement("True")}}
+		model.Segmentation.Segments = append(model.Segmentation.Segments, pmmlSegment{
+			ID:        strconv.Itoa(t + 1),
+			Predicate: []pmmlPredicate{{XMLName: pmmlElement("True")}},
+			TreeModel: &pmmlTreeModel{
+				FunctionName:         functionName,
+				MissingValueStrategy: "defaultChild",
+				NoTrueChildStrategy:  "returnLastPrediction",
+				SplitCharacteristic:  "binarySplit",
+				MiningSchema:         schema,
+				Node:                 root,
+			},
+		})
+	}
+	doc.MiningModel = model
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ExportPMML writes the forest as a PMML 4.4 MiningModel averaging the class
+// probabilities of one TreeModel per tree, like PredictProba without
+// calibration. Features are named x0, x1, ... and the target y.
+func (forest *ClassificationForest[F, L]) ExportPMML(w io.Writer) error {
+	fields, schema := pmmlDictionary(forest.BaseForest)
+	labels, _ := labelDictionary(forest.Trees, nil)
+	dataType, _ := pmmlDataType[L]()
+	target := pmmlDataField{Name: pmmlTarget, Optype: "categorical", DataType: dataType}
+	for _, l := range labels {
+		target.Values = append(target.Values, pmmlValue{Value: formatFeature(l)})
+	}
+	trees := make([]pmmlNode, len(forest.Trees))
+	for t, tree := range forest.Trees {
+		next := 0
+		trees[t] = exportPMMLClassificationNode(tree.Root, &next)
+	}
+	return writePMML(w, "classification", append(fields, target), schema, trees)
+}
+
+// ExportPMML writes the forest as a PMML 4.4 MiningModel averaging one
+// TreeModel per tree like Predicate. Features are named x0, x1, ... and the
+// target y.
+func (forest *RegressionForest[F]) ExportPMML(w io.Writer) error {
+	fields, schema := pmmlDictionary(forest.BaseForest)
+	target := pmmlDataField{Name: pmmlTarget, Optype: "continuous", DataType: "double"}
+	trees := make([]pmmlNode, len(forest.Trees))
+	for t, tree := range forest.Trees {
+		next := 0
+		trees[t] = exportPMMLRegressionNode(tree.Root, &next)
+	}
+	return writePMML(w, "regression", append(fields, target), schema, trees)
+}
+
+// pmmlImport holds the columns of a PMML model being imported.
+type pmmlImport[F Feature] struct {
+	columns map[string]int
+	schema  []ColumnType
+	missing []MissingMarker[F]
+}
+
+// pmmlSplitOf is a binary split read from the predicate of a first child.
+// swap is set if the predicate selects what the library sends right.
+type pmmlSplitOf[F Feature] struct {
+	column      int
+	columnType  ColumnType
+	value       *F
+	categories  []F
+	missing     *F
+	missingLeft bool
+	surrogates  []Surrogate[F]
+	swap        bool
+}
+
+func (p *pmmlImport[F]) column(field string) (int, error) {
+	c, ok := p.columns[field]
+	if !ok {
+		return 0, fmt.Errorf("unknown field %q", field)
+	}
+	return c, nil
+}
+
+func (p *pmmlImport[F]) marker(c int) *F {
+	for _, m := range p.missing {
+		if m.Column == c {
+			v := m.Value
+			return &v
+		}
+	}
+	return nil
+}
+
+// test converts a simple predicate into the library's "<=" or "in" test.
+// complement is set if the predicate is the negation of that test.
+func (p *pmmlImport[F]) test(pred *pmmlPredicate) (column int, columnType ColumnType, value *F, categories []F, complement bool, err error) {
+	if column, err = p.column(pred.Field); err != nil {
+		return
+	}
+	switch pred.XMLName.Local {
+	case "SimplePredicate":
+		if pred.Value == nil {
+			return 0, 0, nil, nil, false, fmt.Errorf("operator %q without value", pred.Operator)
+		}
+		var v F
+		if v, err = parseFeature[F](*pred.Value); err != nil {
+			return
+		}
+		switch pred.Operator {
+		case "lessOrEqual", "greaterThan":
+			return column, NUMERIC, &v, nil, pred.Operator == "greaterThan", nil
+		case "lessThan", "greaterOrEqual":
+			below, err := pmmlBelow(v)
+			return column, NUMERIC, &below, nil, pred.Operator == "greaterOrEqual", err
+		case "equal", "notEqual":
+			return column, CAT, &v, []F{v}, pred.Operator == "notEqual", nil
+		}
+		return 0, 0, nil, nil, false, fmt.Errorf("unsupported operator %q", pred.Operator)
+	case "SimpleSetPredicate":
+		if pred.Array == nil || (pred.BooleanOperator != "isIn" && pred.BooleanOperator != "isNotIn") {
+			return 0, 0, nil, nil, false, fmt.Errorf("unsupported set predicate %q", pred.BooleanOperator)
+		}
+		values, err := parseArray(pred.Array)
+		if err != nil {
+			return 0, 0, nil, nil, false, err
+		}
+		categories := make([]F, len(values))
+		for i, s := range values {
+			if categories[i], err = parseFeature[F](s); err != nil {
+				return 0, 0, nil, nil, false, err
+			}
+		}
+		slices.Sort(categories)
+		return column, CAT, nil, slices.Compact(categories), pred.BooleanOperator == "isNotIn", nil
+	}
+	return 0, 0, nil, nil, false, fmt.Errorf("unsupported predicate %s", pred.XMLName.Local)
+}
+
+// pmmlBelow returns the largest value less than v, so x < v becomes x <= it.
+func pmmlBelow[F Feature](v F) (F, error) {
+	r := reflect.ValueOf(&v).Elem()
+	switch r.Kind() {
+	case reflect.Float64:
+		r.SetFloat(math.Nextafter(r.Float(), math.Inf(-1)))
+	case reflect.String:
+		return v, errors.New("ordered comparison of string field")
+	default:
+		r.SetInt(r.Int() - 1)
+	}
+	return v, nil
+}
+
+// split reads the split of node from the predicate of its first child.
+func (p *pmmlImport[F]) split(node *pmmlNode, first *pmmlNode) (pmmlSplitOf[F], error) {
+	var s pmmlSplitOf[F]
+	defaultFirst := node.DefaultChild != "" && node.DefaultChild == first.ID
+	pred := predicateOf(first)
+	if pred == nil {
+		return s, errors.New("node without predicate")
+	}
+	if pred.XMLName.Local != "CompoundPredicate" {
+		var err error
+		if s.column, s.columnType, s.value, s.categories, s.swap, err = p.test(pred); err != nil {
+			return s, err
+		}
+		s.missingLeft = defaultFirst != s.swap
+		s.missing = p.marker(s.column)
+		return s, nil
+	}
+	if pred.BooleanOperator != "surrogate" || len(pred.Predicates) == 0 {
+		return s, fmt.Errorf("unsupported compound predicate %q", pred.BooleanOperator)
+	}
+	var err error
+	if s.column, s.columnType, s.value, s.categories, s.swap, err = p.test(&pred.Predicates[0]); err != nil {
+		return s, err
+	}
+	s.missing = p.marker(s.column)
+	s.missingLeft = defaultFirst != s.swap
+	for k := 1; k < len(pred.Predicates); k++ {
+		sub := &pred.Predicates[k]
+		switch sub.XMLName.Local {
+		case "True", "False":
+			if k != len(pred.Predicates)-1 {
+				return s, errors.New("constant surrogate before the last position")
+			}
+			s.missingLeft = (sub.XMLName.Local == "True") != s.swap
+			continue
+		}
+		column, columnType, value, categories, complement, err := p.test(sub)
+		if err != nil {
+			return s, err
+		}
+		if columnType != NUMERIC && len(categories) > 1 {
+			value = nil
+		}
+		s.surrogates = append(s.surrogates, Surrogate[F]{Column: column, Type: columnType, Value: value, Categories: categories, Missing: p.marker(column), Reverse: complement != s.swap})
+	}
+	return s, nil
+}
+
+func predicateOf(node *pmmlNode) *pmmlPredicate {
+	for k := range node.Predicate {
+		switch node.Predicate[k].XMLName.Local {
+		case "True", "False", "SimplePredicate", "SimpleSetPredicate", "CompoundPredicate":
+			return &node.Predicate[k]
+		}
+	}
+	return nil
+}
+
+// importTree converts a PMML node into a binary tree. A node with one child
+// is a split whose other side predicts like the node itself, as under
+// noTrueChildStrategy "returnLastPrediction". The second of two children is
+// taken whenever the first is not; nodes with more children are rejected.
+func importTree[F Feature, N any](p *pmmlImport[F], node *pmmlNode, depth int, leaf func(*pmmlNode) (N, error), branch func(s pmmlSplitOf[F], left, right N, size int) N) (N, error) {
+	var zero N
+	if depth > maxDecodeDepth {
+		return zero, errors.New("tree too deep")
+	}
+	switch len(node.Nodes) {
+	case 0:
+		return leaf(node)
+	case 1, 2:
+	default:
+		return zero, fmt.Errorf("node %s has %d children, want a binary split", node.ID, len(node.Nodes))
+	}
+	first := &node.Nodes[0]
+	if pred := predicateOf(first); pred != nil && pred.XMLName.Local == "True" {
+		return importTree(p, first, depth+1, leaf, branch)
+	}
+	s, err := p.split(node, first)
+	if err != nil {
+		return zero, err
+	}
+	left, err := importTree(p, first, depth+1, leaf, branch)
+	if err != nil {
+		return zero, err
+	}
+	var right N
+	if len(node.Nodes) == 2 {
+		right, err = importTree(p, &node.Nodes[1], depth+1, leaf, branch)
+	} else {
+		right, err = leaf(node)
+	}
+	if err != nil {
+		return zero, err
+	}
+	if s.swap {
+		left, right = right, left
+	}
+	return branch(s, left, right, int(node.RecordCount)), nil
+}
+
+// readPMML parses a PMML document and returns its trees and columns.
+func readPMML[F Feature](r io.Reader, functionName string) ([]*pmmlNode, *pmmlImport[F], error) {
+	var doc pmmlDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, nil, corruptModel(err)
+	}
+	var schema pmmlMiningSchema
+	var trees []*pmmlNode
+	switch {
+	case doc.MiningModel != nil:
+		model := doc.MiningModel
+		if model.FunctionName != functionName {
+			return nil, nil, corruptModel(fmt.Errorf("%s model, want %s", model.FunctionName, functionName))
+		}
+		if model.Segmentation == nil {
+			return nil, nil, corruptModel(errors.New("mining model without segmentation"))
+		}
+		// The forests average their trees' votes; majorityVote would count
+		// one vote per tree instead and predict something else.
+		if model.Segmentation.MultipleModelMethod != "average" {
+			return nil, nil, corruptModel(fmt.Errorf("unsupported multiple model method %q", model.Segmentation.MultipleModelMethod))
+		}
+		schema = model.MiningSchema
+		for k := range model.Segmentation.Segments {
+			segment := &model.Segmentation.Segments[k]
+			if pred := predicateOf(&pmmlNode{Predicate: segment.Predicate}); pred != nil && pred.XMLName.Local != "True" {
+				return nil, nil, corruptModel(fmt.Errorf("segment %s has a %s predicate", segment.ID, pred.XMLName.Local))
+			}
+			if segment.TreeModel == nil {
+				return nil, nil, corruptModel(fmt.Errorf("segment %s is not a tree model", segment.ID))
+			}
+			trees = append(trees, &segment.TreeModel.Node)
+		}
+	case doc.TreeModel != nil:
+		if doc.TreeModel.FunctionName != functionName {
+			return nil, nil, corruptModel(fmt.Errorf("%s model, want %s", doc.TreeModel.FunctionName, functionName))
+		}
+		schema = doc.TreeModel.MiningSchema
+		trees = append(trees, &doc.TreeModel.Node)
+	}
+	if len(trees) == 0 {
+		return nil, nil, corruptModel(errors.New("no trees"))
+	}
+
+	dictionary := make(map[string]*pmmlDataField, len(doc.DataDictionary.Fields))
+	for k := range doc.DataDictionary.Fields {
+		dictionary[doc.DataDictionary.Fields[k].Name] = &doc.DataDictionary.Fields[k]
+	}
+	p := &pmmlImport[F]{columns: make(map[string]int)}
+	for _, f := range schema.Fields {
+		switch f.UsageType {
+		case "", "active":
+		default:
+			continue
+		}
+		field, ok := dictionary[f.Name]
+		if !ok {
+			return nil, nil, corruptModel(fmt.Errorf("field %q not in data dictionary", f.Name))
+		}
+		c := len(p.schema)
+		p.columns[f.Name] = c
+		columnType := NUMERIC
+		if field.Optype != "continuous" {
+			columnType = CAT
+		}
+		p.schema = append(p.schema, columnType)
+		for _, v := range field.Values {
+			if v.Property == "missing" && p.marker(c) == nil {
+				value, err := parseFeature[F](v.Value)
+				if err != nil {
+					return nil, nil, corruptModel(err)
+				}
+				p.missing = append(p.missing, MissingMarker[F]{Column: c, Value: value})
+			}
+		}
+	}
+	return trees, p, nil
+}
+
+// ReadForestPMML reads a classification random forest from PMML: a
+// MiningModel averaging TreeModels, or a single TreeModel. Feature columns
+// follow the order of the active fields in the mining schema. Splits must be
+// binary with the test on the first child; a node without a default child
+// sends missing values to its second child.
+func ReadForestPMML[T Feature, L Label](r io.Reader) (*ClassificationForest[T, L], error) {
+	trees, p, err := readPMML[T](r, "classification")
+	if err != nil {
+		return nil, err
+	}
+	forest := &ClassificationForest[T, L]{BaseForest: &BaseForest[T]{Features: len(p.schema), Schema: p.schema, Missing: p.missing}}
+	classes := make(map[L]bool)
+	leaf := func(node *pmmlNode) (*ClassificationNode[T, L], error) {
+		// Weigh the leaf by record counts when it has any, else by
+		// probabilities, never a mix of both.
+		counts := false
+		for _, d := range node.ScoreDistributions {
+			counts = counts || d.RecordCount > 0
+		}
+		labels := make(map[L]float64)
+		for _, d := range node.ScoreDistributions {
+			l, err := parseFeature[L](d.Value)
+			if err != nil {
+				return nil, err
+			}
+			if counts {
+				if d.RecordCount > 0 {
+					labels[l] += d.RecordCount
+				}
+			} else if d.Probability != nil && *d.Probability > 0 {
+				labels[l] += *d.Probability
+			}
+		}
+		if len(labels) == 0 {
+			if node.Score == "" {
+				return nil, fmt.Errorf("leaf %s without score", node.ID)
+			}
+			l, err := parseFeature[L](node.Score)
+			if err != nil {
+				return nil, err
+			}
+			labels[l] = 1
+		}
+		for l := range labels {
+			classes[l] = true
+		}
+		return &ClassificationNode[T, L]{Size: int(node.RecordCount), Labels: labels}, nil
+	}
+	branch := func(s pmmlSplitOf[T], left, right *ClassificationNode[T, L], size int) *ClassificationNode[T, L] {
+		return &ClassificationNode[T, L]{Size: size, Column: s.column, Type: s.columnType, Value: s.value, Categories: s.categories, Missing: s.missing, MissingLeft: s.missingLeft, Surrogates: s.surrogates, Left: left, Right: right}
+	}
+	for _, root := range trees {
+		node, err := importTree(p, root, 0, leaf, branch)
+		if err != nil {
+			return nil, corruptModel(err)
+		}
+		forest.Trees = append(forest.Trees, &ClassificationTree[T, L]{Root: node})
+	}
+	forest.Classes = len(classes)
+	return forest, nil
+}
+
+// ReadRegressionForestPMML reads a regression random forest from PMML like
+// ReadForestPMML.
+func ReadRegressionForestPMML[F Feature](r io.Reader) (*RegressionForest[F], error) {
+	trees, p, err := readPMML[F](r, "regression")
+	if err != nil {
+		return nil, err
+	}
+	forest := &RegressionForest[F]{BaseForest: &BaseForest[F]{Features: len(p.schema), Schema: p.schema, Missing: p.missing}}
+	leaf := func(node *pmmlNode) (*RegressionNode[F], error) {
+		label, err := strconv.ParseFloat(strings.TrimSpace(node.Score), 64)
+		if err != nil {
+			return nil, fmt.Errorf("leaf %s: %w", node.ID, err)
+		}
+		return &RegressionNode[F]{Size: int(node.RecordCount), Label: label}, nil
+	}
+	branch := func(s pmmlSplitOf[F], left, right *RegressionNode[F], size int) *RegressionNode[F] {
+		return &RegressionNode[F]{Size: size, Column: s.column, Type: s.columnType, Value: s.value, Categories: s.categories, Missing: s.missing, MissingLeft: s.missingLeft, Surrogates: s.surrogates, Left: left, Right: right}
+	}
+	for _, root := range trees {
+		node, err := importTree(p, root, 0, leaf, branch)
+		if err != nil {
+			return nil, corruptModel(err)
+		}
+		forest.Trees = append(forest.Trees, &RegressionTree[F]{Root: node})
+	}
+	return forest, nil
+}
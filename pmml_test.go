@@ -0,0 +1,127 @@
+package randomForest
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPMMLClassifierRoundTrip(t *testing.T) {
+	x, y := onnxIris(t)
+	forest := NewClassificationForest[float64, string](1000, 30, 1, 0.5)
+	forest.MaxDepth = 10
+	forest.MaxSurrogates = 2
+	forest.Seed = 1
+	forest.Schema = []ColumnType{NUMERIC, CAT, NUMERIC, NUMERIC}
+	forest.Missing = []MissingMarker[float64]{{Column: 2, Value: -1}}
+	if err := forest.TryTrain(x, y, 30); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := forest.ExportPMML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadForestPMML[float64, string](&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range x {
+		if got, want := loaded.PredicateWithData(row), forest.PredicateWithData(row); !sameVotes(got, want) {
+			t.Fatalf("row %d: PMML votes %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestPMMLCarsRoundTrip(t *testing.T) {
+	x, y := loadCars(t)
+	forest := NewClassificationForest[string, string](2000, 10, 1, 0.5)
+	forest.MaxDepth = 10
+	forest.Seed = 1
+	if err := forest.TryTrain(x, y, 10); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := forest.ExportPMML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadForestPMML[string, string](&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range x {
+		if got, want := loaded.PredicateWithData(row), forest.PredicateWithData(row); !sameVotes(got, want) {
+			t.Fatalf("row %d: PMML votes %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestPMMLRegressorRoundTrip(t *testing.T) {
+	forest, inputs := trainedSin(t)
+	var buf bytes.Buffer
+	if err := forest.ExportPMML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadRegressionForestPMML[float64](&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, input := range inputs {
+		if got, want := loaded.Predicate(input), forest.Predicate(input); math.Abs(got-want) > 1e-9 {
+			t.Fatalf("row %d: PMML predicts %v, want %v", i, got, want)
+		}
+	}
+}
+
+const pmmlStump = `<PMML xmlns="http://www.dmg.org/PMML-4_4" version="4.4">
+<DataDictionary>
+<DataField name="x" optype="continuous" dataType="double"/>
+<DataField name="y" optype="categorical" dataType="string"/>
+</DataDictionary>
+<MiningModel functionName="classification">
+<MiningSchema><MiningField name="x"/><MiningField name="y" usageType="target"/></MiningSchema>
+<Segmentation multipleModelMethod="METHOD">
+<Segment id="1"><True/>
+<TreeModel functionName="classification">
+<MiningSchema><MiningField name="x"/><MiningField name="y" usageType="target"/></MiningSchema>
+<Node id="0">
+<True/>
+<Node id="1" score="a">
+<SimplePredicate field="x" operator="lessOrEqual" value="1"/>
+<ScoreDistribution value="a" recordCount="3"/>
+<ScoreDistribution value="b" probability="0.9"/>
+</Node>
+<Node id="2" score="b">
+<True/>
+<ScoreDistribution value="a" probability="0.25"/>
+<ScoreDistribution value="b" probability="0.75"/>
+</Node>
+</Node>
+</TreeModel>
+</Segment>
+</Segmentation>
+</MiningModel>
+</PMML>`
+
+func TestPMMLRejectsMajorityVote(t *testing.T) {
+	_, err := ReadForestPMML[float64, string](strings.NewReader(strings.Replace(pmmlStump, "METHOD", "majorityVote", 1)))
+	if !errors.Is(err, ErrCorruptModel) {
+		t.Fatalf("got %v, want %v", err, ErrCorruptModel)
+	}
+}
+
+// A leaf with record counts ignores the probabilities next to them; a leaf
+// without any is weighed by its probabilities.
+func TestPMMLLeafUnits(t *testing.T) {
+	forest, err := ReadForestPMML[float64, string](strings.NewReader(strings.Replace(pmmlStump, "METHOD", "average", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := forest.PredictProba([]float64{0}); got["a"] != 1 || got["b"] != 0 {
+		t.Fatalf("got %v for the counted leaf, want a: 1", got)
+	}
+	if got := forest.PredictProba([]float64{2}); got["a"] != 0.25 || got["b"] != 0.75 {
+		t.Fatalf("got %v for the probability leaf, want a: 0.25, b: 0.75", got)
+	}
+}
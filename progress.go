@@ -0,0 +1,95 @@
+package randomForest
+
+import (
+	"context"
+	"log/slog"
+)
+
+// ProgressReporter receives training progress. Calls are serialized, so
+// implementations do not need to be safe for concurrent use.
+type ProgressReporter interface {
+	TreeStarted(tree, total int)
+	TreeFinished(tree, total int, oob float64, nodes int)
+	TrainingDone(trees int, err error)
+}
+
+type NopProgress struct{}
+
+func (NopProgress) TreeStarted(tree, total int)                          {}
+func (NopProgress) TreeFinished(tree, total int, oob float64, nodes int) {}
+func (NopProgress) TrainingDone(trees int, err error)                    {}
+
+type SlogProgress struct {
+	Logger *slog.Logger
+	Level  slog.Level
+}
+
+func NewSlogProgress(logger *slog.Logger) *SlogProgress {
+	return &SlogProgress{Logger: logger, Level: slog.LevelInfo}
+}
+
+func (p *SlogProgress) TreeStarted(tree, total int) {
+	p.Logger.Log(context.Background(), p.Level, "building tree", "tree", tree, "total", total)
+}
+
+func (p *SlogProgress) TreeFinished(tree, total int, oob float64, nodes int) {
+	p.Logger.Log(context.Background(), p.Level, "tree done", "tree", tree, "total", total, "oob", oob, "nodes", nodes)
+}
+
+func (p *SlogProgress) TrainingDone(trees int, err error) {
+	if err != nil {
+		p.Logger.Log(context.Background(), slog.LevelError, "training failed", "trees", trees, "err", err)
+		return
+	}
+	p.Logger.Log(context.Background(), p.Level, "training done", "trees", trees)
+}
+
+type ProgressKind int
+
+const (
+	EventTreeStarted ProgressKind = iota
+	EventTreeFinished
+	EventTrainingDone
+)
+
+// ProgressEvent is one ProgressReporter call. Total, set on tree events, is
+// the number of trees requested; Built, set on EventTrainingDone, is the
+// number actually built.
+type ProgressEvent struct {
+	Kind  ProgressKind
+	Tree  int
+	Total int
+	Built int
+	OOB   float64
+	Nodes int
+	Err   error
+}
+
+// ChanProgress sends every event to C. Sends block, so the receiver has to
+// keep draining C while training runs.
+type ChanProgress struct {
+	C chan<- ProgressEvent
+}
+
+func NewChanProgress(c chan<- ProgressEvent) *ChanProgress {
+	return &ChanProgress{C: c}
+}
+
+func (p *ChanProgress) TreeStarted(tree, total int) {
+	p.C <- ProgressEvent{Kind: EventTreeStarted, Tree: tree, Total: total}
+}
+
+func (p *ChanProgress) TreeFinished(tree, total int, oob float64, nodes int) {
+	p.C <- ProgressEvent{Kind: EventTreeFinished, Tree: tree, Total: total, OOB: oob, Nodes: nodes}
+}
+
+func (p *ChanProgress) TrainingDone(trees int, err error) {
+	p.C <- ProgressEvent{Kind: EventTrainingDone, Built: trees, Err: err}
+}
+
+func (forest *BaseForest[F]) progress() ProgressReporter {
+	if forest.Progress == nil {
+		return NopProgress{}
+	}
+	return forest.Progress
+}
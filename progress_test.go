@@ -0,0 +1,32 @@
+package randomForest
+
+import "testing"
+
+func TestChanProgress(t *testing.T) {
+	x, y := loadIris(t)
+	events := make(chan ProgressEvent, 100)
+	forest := NewClassificationForest[float64, string](1000, 5, 1, 0.5)
+	forest.MaxDepth = 10
+	forest.Progress = NewChanProgress(events)
+	if err := forest.TryTrain(x, y, 5); err != nil {
+		t.Fatal(err)
+	}
+	close(events)
+	counts := make(map[ProgressKind]int)
+	var done ProgressEvent
+	for e := range events {
+		counts[e.Kind]++
+		if e.Kind != EventTrainingDone && e.Total != 5 {
+			t.Fatalf("%v event with Total %d, want 5", e.Kind, e.Total)
+		}
+		if e.Kind == EventTrainingDone {
+			done = e
+		}
+	}
+	if counts[EventTreeStarted] != 5 || counts[EventTreeFinished] != 5 || counts[EventTrainingDone] != 1 {
+		t.Fatalf("got events %v", counts)
+	}
+	if done.Built != 5 || done.Err != nil {
+		t.Fatalf("got done event %+v", done)
+	}
+}
@@ -0,0 +1,48 @@
+package randomForest
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+)
+
+// treeRand returns the random stream of the x-th tree of a forest seeded with
+// seed. Streams only depend on seed and x, never on scheduling.
+func treeRand(seed int64, x int) *rand.Rand {
+	z := uint64(seed) + uint64(x+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z = z ^ (z >> 31)
+	return rand.New(rand.NewSource(int64(z)))
+}
+
+func newRand() *rand.Rand {
+	return rand.New(rand.NewSource(rand.Int63()))
+}
+
+// seed returns the seed for read-only analysis, a fresh random one if the
+// forest has none. Unlike trainingSeed it never changes the forest.
+func (forest *BaseForest[F]) seed() int64 {
+	if forest.Seed == 0 {
+		return rand.Int63()
+	}
+	return forest.Seed
+}
+
+// trainingSeed returns the seed trees are grown from and stores a random one
+// if the forest has none, so later training continues the same streams.
+func (forest *BaseForest[F]) trainingSeed() int64 {
+	if forest.Seed == 0 {
+		forest.Seed = rand.Int63()
+	}
+	return forest.Seed
+}
+
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,53 @@
+package randomForest
+
+import (
+	"bytes"
+	"testing"
+)
+
+func trainIris(t *testing.T, cpus int) []byte {
+	withCPUs(t, cpus)
+	x, y := loadIris(t)
+	forest := NewClassificationForest[float64, string](1000, 40, 1, 0.5)
+	forest.MaxDepth = 10
+	forest.Seed = 42
+	if err := forest.TryTrain(x, y, 40); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := forest.Save(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestSeedDumpsIdenticalAcrossCPUs(t *testing.T) {
+	serial := trainIris(t, 1)
+	parallel := trainIris(t, 8)
+	if !bytes.Equal(serial, parallel) {
+		t.Fatal("dumps differ between NUM_CPU=1 and NUM_CPU=8")
+	}
+	if again := trainIris(t, 8); !bytes.Equal(parallel, again) {
+		t.Fatal("dumps differ between two runs with NUM_CPU=8")
+	}
+}
+
+func TestAnalysisKeepsSeed(t *testing.T) {
+	x, y := loadIris(t)
+	forest := NewClassificationForest[float64, string](1000, 10, 1, 0.5)
+	forest.MaxDepth = 10
+	if err := forest.TryTrain(x, y, 10); err != nil {
+		t.Fatal(err)
+	}
+	seed := forest.Seed
+	if seed == 0 {
+		t.Fatal("training did not store a seed")
+	}
+	forest.Seed = 0
+	if _, err := forest.PermutationImportanceOn(x, y, 1); err != nil {
+		t.Fatal(err)
+	}
+	if forest.Seed != 0 {
+		t.Fatalf("PermutationImportanceOn stored seed %d", forest.Seed)
+	}
+}
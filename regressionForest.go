@@ -1,13 +1,10 @@
 package randomForest
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"math"
 	"math/rand"
 	"os"
-	"sync"
-	"time"
 )
 
 type RegressionForest[F Feature] struct {
@@ -17,6 +14,12 @@ type RegressionForest[F Feature] struct {
 	Range  float64
 }
 
+// MeanDecreaseImpurity weighs each feature by how much its splits reduce the
+// mean squared error; see meanDecreaseImpurity.
+func (f RegressionForest[F]) MeanDecreaseImpurity() []float64 {
+	return meanDecreaseImpurity(f.Trees, f.Features)
+}
+
 func (f RegressionForest[F]) Importance() []float64 {
 	imp := make([]float64, f.Features)
 	for i := 0; i < len(f.Trees); i++ {
@@ -39,14 +42,35 @@ func NewRegressionForest[F Feature](bufferSize int, treeLimit int, samplesAmount
 }
 
 func (forest *RegressionForest[F]) Train(inputs [][]F, labels []float64, treesAmount int) {
-	forest.Data = append(forest.Data, inputs...)
-	if len(forest.Data) > forest.BufferSize {
-		forest.Data = shiftLeft(forest.Data, len(forest.Data)-forest.BufferSize)
+	if err := forest.TryTrain(inputs, labels, treesAmount); err != nil {
+		panic(err)
+	}
+}
+
+func (forest *RegressionForest[F]) TryTrain(inputs [][]F, labels []float64, treesAmount int) error {
+	return forest.TrainContext(context.Background(), inputs, labels, treesAmount)
+}
+
+// TrainContext adds treesAmount trees trained on the stored rows plus inputs.
+// The inputs are appended to Data and Labels before training starts and stay
+// there if ctx is cancelled; the trees finished by then are kept too, and
+// ctx.Err() is returned.
+func (forest *RegressionForest[F]) TrainContext(ctx context.Context, inputs [][]F, labels []float64, treesAmount int) error {
+	features := 0
+	if len(forest.Data) > 0 {
+		features = forest.Features
+	}
+	if err := validateInputs(inputs, len(labels), features); err != nil {
+		return err
 	}
-	forest.Labels = append(forest.Labels, labels...)
-	if len(forest.Labels) > forest.BufferSize {
-		forest.Labels = shiftLeft(forest.Labels, len(forest.Labels)-forest.BufferSize)
+	if err := forest.validateSchema(len(inputs[0])); err != nil {
+		return err
 	}
+	if err := forest.validateBins(); err != nil {
+		return err
+	}
+	forest.appendData(inputs)
+	forest.Labels = appendBuffer(forest.Labels, labels, forest.BufferSize)
 	vMin := math.MaxFloat64
 	vMax := -math.MaxFloat64
 	for _, v := range forest.Labels {
@@ -59,57 +83,59 @@ func (forest *RegressionForest[F]) Train(inputs [][]F, labels []float64, treesAm
 	}
 
 	forest.Range = vMax - vMin
+	forest.setSizes(len(inputs[0]))
+
+	seed := forest.trainingSeed()
+	offset := len(forest.Trees)
+	trees, err := buildTrees(ctx, forest.progress(), treesAmount, func(ctx context.Context, x int) (*RegressionTree[F], error) {
+		return forest.buildTree(ctx, treeRand(seed, offset+x))
+	})
+	forest.Trees = append(forest.Trees, trees...)
+	return err
+}
 
-	forest.Features = len(inputs[0])
-	forest.MFeatures = int(float64(forest.Features) * forest.MFeaturesFactor)
-	forest.NSize = int(float64(len(forest.Data)) * forest.NSizeFactor)
-	prog_counter := 0
-	mutex := &sync.Mutex{}
-	s := make(chan bool, NUM_CPU)
-	for i := 0; i < treesAmount; i++ {
-		s <- true
-		go func(x int) {
-			defer func() { <-s }()
-
-			fmt.Printf(">> %v buiding %vth tree...\n", time.Now(), x)
-			tree := forest.BuildTree()
-			forest.Trees = append(forest.Trees, tree)
-			//fmt.Printf("<< %v the %vth tree is done.\n",time.Now(), x)
-			mutex.Lock()
-			prog_counter += 1
-			fmt.Printf("%v tranning progress %.0f%%\n", time.Now(), float64(prog_counter)/float64(treesAmount)*100)
-			mutex.Unlock()
-		}(i)
+func (forest *RegressionForest[F]) BuildTree() *RegressionTree[F] {
+	tree, err := forest.TryBuildTree()
+	if err != nil {
+		panic(err)
 	}
+	return tree
+}
 
-	for i := 0; i < NUM_CPU; i++ {
-		s <- true
-	}
+func (forest *RegressionForest[F]) TryBuildTree() (*RegressionTree[F], error) {
+	return forest.buildTree(context.Background(), newRand())
 }
 
-func (forest *RegressionForest[F]) BuildTree() *RegressionTree[F] {
+func (forest *RegressionForest[F]) buildTree(ctx context.Context, rng *rand.Rand) (*RegressionTree[F], error) {
+	if len(forest.Data) == 0 || forest.NSize == 0 {
+		return nil, ErrEmptyInput
+	}
 	samples := make([][]F, forest.NSize)
 	samples_labels := make([]float64, forest.NSize)
+	rows := make([]int, forest.NSize)
 	used := make([]bool, len(forest.Data))
 	for i := 0; i < forest.NSize; i++ {
-		j := rand.Intn(len(forest.Data))
+		j := rng.Intn(len(forest.Data))
 		samples[i] = forest.Data[j]
+		rows[i] = j
 		samples_labels[i] = forest.Labels[j]
-		used[i] = true
+		used[j] = true
 	}
 
-	tree := &RegressionTree[F]{}
-	tree.Root = buildRegressionNode(samples, samples_labels, forest.MFeatures, forest.MaxDepth)
-	count := 0
+	tree := &RegressionTree[F]{oobRows: forest.oobRows(used)}
+	cfg := forest.growConfig(ctx, rng, forest.MFeatures)
+	tree.Root = buildRegressionNode(cfg, samples, rows, samples_labels, forest.MaxDepth)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	e := 0.0
-	for i := 0; i < len(forest.Data); i++ {
-		if !used[i] {
-			count++
-			e += tree.Predicate(forest.Data[i])
-		}
+	for _, row := range tree.oobRows {
+		e += tree.Predicate(forest.Data[row-forest.evicted])
 	}
-	tree.Validation = math.Abs(e / float64(count))
-	return tree
+	if len(tree.oobRows) > 0 {
+		tree.Validation = math.Abs(e / float64(len(tree.oobRows)))
+	}
+	return tree, nil
 }
 
 func (forest *RegressionForest[F]) Predicate(input []F) float64 {
@@ -137,23 +163,32 @@ func (forest *RegressionForest[F]) WeightedPredicate(input []F) float64 {
 }
 
 func (forest *RegressionForest[F]) DumpForest(fileName string) {
-	out_f, err := os.OpenFile(fileName, os.O_CREATE|os.O_RDWR, 0777)
-	if err != nil {
-		panic("failed to create " + fileName)
+	if err := forest.Dump(fileName); err != nil {
+		panic(err)
 	}
-	defer out_f.Close()
-	encoder := json.NewEncoder(out_f)
-	encoder.Encode(forest)
+}
+
+func (forest *RegressionForest[F]) Dump(fileName string) error {
+	return writeFileAtomic(fileName, forest.Save)
 }
 
 func LoadRegressionForest[F Feature](fileName string) *RegressionForest[F] {
+	forest, err := ReadRegressionForest[F](fileName)
+	if err != nil {
+		panic(err)
+	}
+	return forest
+}
+
+func ReadRegressionForest[F Feature](fileName string) (*RegressionForest[F], error) {
 	in_f, err := os.Open(fileName)
 	if err != nil {
-		panic("failed to open " + fileName)
+		return nil, err
 	}
 	defer in_f.Close()
-	decoder := json.NewDecoder(in_f)
 	forest := &RegressionForest[F]{}
-	decoder.Decode(forest)
-	return forest
+	if err := forest.Load(in_f); err != nil {
+		return nil, err
+	}
+	return forest, nil
 }
@@ -1,23 +1,46 @@
 package randomForest
 
 import (
+	"context"
 	"math/rand"
-	"reflect"
 )
 
 type RegressionTree[F Feature] struct {
 	Root       *RegressionNode[F]
 	Validation float64
+	oobRows    []int
 }
 
 type RegressionNode[F Feature] struct {
-	Size    int
-	Value   *F
-	Left    *RegressionNode[F]
-	Right   *RegressionNode[F]
-	Column  int
-	Label   float64
-	Measure float64
+	Size        int
+	Value       *F
+	Categories  []F `json:",omitempty" bson:",omitempty"`
+	Left        *RegressionNode[F]
+	Right       *RegressionNode[F]
+	Column      int
+	Type        ColumnType
+	Missing     *F             `json:",omitempty" bson:",omitempty"`
+	MissingLeft bool           `json:",omitempty" bson:",omitempty"`
+	Surrogates  []Surrogate[F] `json:",omitempty" bson:",omitempty"`
+	Gain        float64        `json:",omitempty" bson:",omitempty"`
+	Label       float64
+	Measure     float64
+}
+
+// mdi returns the impurity decrease per feature, normalized to sum to 1.
+func (tree RegressionTree[F]) mdi(nFeatures int) []float64 {
+	imp := make([]float64, nFeatures)
+	tree.Root.decrease(imp)
+	sum := 0.0
+	for _, v := range imp {
+		sum += v
+	}
+	if sum > 0 {
+		for i := range imp {
+			imp[i] /= sum
+		}
+	}
+	return imp
 }
 
 func (tree RegressionTree[F]) importance(nFeatures int) []float64 {
@@ -36,6 +59,17 @@ func (tree RegressionTree[F]) importance(nFeatures int) []float64 {
 	return imp
 }
 
+// decrease adds the weighted impurity decrease of every split below node to
+// the column it splits on. Leaves contribute nothing.
+func (node *RegressionNode[F]) decrease(imp []float64) {
+	if node == nil || node.isLeaf() {
+		return
+	}
+	imp[node.Column] += float64(node.Size) * node.Gain
+	node.Left.decrease(imp)
+	node.Right.decrease(imp)
+}
+
 func (node RegressionNode[F]) importance(imp []float64) {
 	imp[node.Column] += float64(node.Size) * node.Measure
 	if node.Left != nil {
@@ -64,110 +98,124 @@ func getMSE(labels []float64) float64 {
 	return mse
 }
 
-func getBestMSEGain[F Feature](samples [][]F, c int, samples_labels []float64, column_type ColumnType, current_mse float64) (float64, F, int, int) {
+func getBestMSEGain[F Feature](samples [][]F, c int, samples_labels []float64, column_type ColumnType, current_mse float64, bins *binnedColumn[F]) split[F] {
+	if column_type == NUMERIC && bins != nil {
+		return getBestHistogramMSEGain(samples_labels, bins)
+	}
+	if column_type == NUMERIC {
+		return getBestNumericMSEGain(samples, c, samples_labels, current_mse)
+	}
+
+	return getBestCategoricalMSEGain(samples, c, samples_labels)
+}
+
+// getBestNumericMSEGain sorts the column once and sweeps running sums of the
+// labels, scoring every "<= value" threshold as current_mse minus the
+// weighted MSE of both sides. Labels are shifted by the first one so constant
+// labels give an exact zero MSE.
+func getBestNumericMSEGain[F Feature](samples [][]F, c int, samples_labels []float64, current_mse float64) split[F] {
 	var best_value F
 	best_gain := 0.0
 	best_total_r := 0
 	best_total_l := 0
 
-	uniq_values := make(map[F]int)
-	for i := 0; i < len(samples); i++ {
-		uniq_values[samples[i][c]] = 1
-	}
-
-	for value := range uniq_values {
-		labels_l := make([]float64, 0)
-		labels_r := make([]float64, 0)
-		total_l := 0
-		total_r := 0
-		if column_type == CAT {
-			for j := 0; j < len(samples); j++ {
-				if samples[j][c] == value {
-					total_l += 1
-					labels_l = append(labels_l, samples_labels[j])
-				} else {
-					total_r += 1
-					labels_r = append(labels_r, samples_labels[j])
-				}
-			}
-		}
-		if column_type == NUMERIC {
-			for j := 0; j < len(samples); j++ {
-				if samples[j][c] <= value {
-					total_l += 1
-					labels_l = append(labels_l, samples_labels[j])
-				} else {
-					total_r += 1
-					labels_r = append(labels_r, samples_labels[j])
-				}
-			}
+	shift := samples_labels[0]
+	sum, sq := 0.0, 0.0
+	for _, y := range samples_labels {
+		d := y - shift
+		sum += d
+		sq += d * d
+	}
+
+	order := sortedOrder(samples, c)
+	sum_l, sq_l := 0.0, 0.0
+	for k, j := range order {
+		d := samples_labels[j] - shift
+		sum_l += d
+		sq_l += d * d
+		if k+1 < len(order) && samples[order[k+1]][c] == samples[j][c] {
+			continue
 		}
+		total_l := k + 1
+		total_r := len(samples) - total_l
 
 		p1 := float64(total_r) / float64(len(samples))
 		p2 := float64(total_l) / float64(len(samples))
 
-		new_mse := p1*getMSE(labels_r) + p2*getMSE(labels_l)
-
-		//fmt.Println(new_mse,part_l,part_r)
+		new_mse := current_mse
+		if total_r > 0 {
+			new_mse = p1*sweepMSE(sum-sum_l, sq-sq_l, total_r) + p2*sweepMSE(sum_l, sq_l, total_l)
+		}
 		mse_gain := current_mse - new_mse
 
-		if mse_gain >= best_gain {
+		if beats(mse_gain, best_gain) {
 			best_gain = mse_gain
-			best_value = value
+			best_value = samples[j][c]
 			best_total_l = total_l
 			best_total_r = total_r
 		}
 	}
 
-	return best_gain, best_value, best_total_l, best_total_r
+	return split[F]{gain: best_gain, value: best_value, totalL: best_total_l, totalR: best_total_r}
+}
+
+// sweepMSE returns the MSE of n labels from their sum and sum of squares.
+func sweepMSE(sum, sq float64, n int) float64 {
+	return max(sq-sum*sum/float64(n), 0) / float64(n)
 }
 
-func buildRegressionNode[F Feature](samples [][]F, samples_labels []float64, selected_feature_count int, depth int) *RegressionNode[F] {
+// buildRegressionNode grows the subtree of samples like buildNode.
+func buildRegressionNode[F Feature](cfg *growConfig[F], samples [][]F, rows []int, samples_labels []float64, depth int) *RegressionNode[F] {
+	if cfg.ctx.Err() != nil {
+		return nil
+	}
 
 	column_count := len(samples[0])
 	//split_count := int(math.Log(float64(column_count)))
-	split_count := selected_feature_count
-	columns_choosen := getRandomRange(column_count, split_count)
+	split_count := cfg.mFeatures
+	columns_choosen := getRandomRange(cfg.rng, column_count, split_count)
 
-	best_gain := 0.0
-	var best_value F
-	var best_column int
-	var best_total_l int = 0
-	var best_total_r int = 0
+	var best split[F]
+	best_column := -1
 	var best_column_type ColumnType
 
 	current_mse := getMSE(samples_labels)
 
 	for _, c := range columns_choosen {
-		column_type := CAT
-
-		if reflect.TypeOf(samples[0][c]) == reflect.TypeFor[float64]() {
-			column_type = NUMERIC
+		if cfg.ctx.Err() != nil {
+			return nil
 		}
+		column_type := cfg.columnType(c, samples[0][c])
 
-		gain, value, total_l, total_r := getBestMSEGain(samples, c, samples_labels, column_type, current_mse)
-		//fmt.Println("kkkkk",gain,part_l,part_r)
-		if gain >= best_gain {
-			best_gain = gain
-			best_total_l = total_l
-			best_total_r = total_r
-			best_value = value
+		s := getBestMSEGainWithMissing(cfg, samples, rows, c, samples_labels, column_type, current_mse)
+		if beatsColumn(s.gain, c, best.gain, best_column) {
+			best = s
 			best_column = c
 			best_column_type = column_type
 		}
 	}
 
-	if best_gain > 0 && best_total_l > 0 && best_total_r > 0 && depth > 0 {
-		//fmt.Println(best_part_l,best_part_r)
+	if best.gain > 0 && best.totalL > 0 && best.totalR > 0 && depth > 0 {
 		node := &RegressionNode[F]{
 			Size:    len(samples_labels),
-			Measure: current_mse + best_gain,
+			Measure: current_mse + best.gain,
+			Gain:    best.gain,
+		}
+		if best_column_type == NUMERIC {
+			node.Value = &best.value
+		} else {
+			node.Categories = best.categories
 		}
-		node.Value = &best_value
 		node.Column = best_column
-		bestPartL, bestPartR := splitSamples(samples, best_column_type, best_column, best_value)
-		node.Left = buildRegressionNode(getSamples(samples, bestPartL), getLabels(samples_labels, bestPartL), selected_feature_count, depth-1)
-		node.Right = buildRegressionNode(getSamples(samples, bestPartR), getLabels(samples_labels, bestPartR), selected_feature_count, depth-1)
+		node.Type = best_column_type
+		node.Missing = best.missing
+		node.MissingLeft = best.missingLeft
+		if cfg.maxSurrogates > 0 {
+			node.Surrogates = findSurrogates(cfg, samples, best_column, best_column_type, best)
+		}
+		bestPartL, bestPartR := splitSamples(samples, best_column_type, best_column, best)
+		node.Left = buildRegressionNode(cfg, getSamples(samples, bestPartL), getLabels(rows, bestPartL), getLabels(samples_labels, bestPartL), depth-1)
+		node.Right = buildRegressionNode(cfg, getSamples(samples, bestPartR), getLabels(rows, bestPartR), getLabels(samples_labels, bestPartR), depth-1)
 		return node
 	}
 
@@ -192,43 +240,100 @@ func genRegressionLeafNode[F Feature](labels []float64) *RegressionNode[F] {
 
 func predicate[T Feature](node *RegressionNode[T], input []T) float64 {
 
-	if reflect.ValueOf(node.Value).IsNil() { //leaf node
+	if node.isLeaf() {
 		return node.Label
 	}
 
-	c := node.Column
-	value := input[c]
-
-	switch reflect.TypeOf(value) {
-	case reflect.TypeFor[float64]():
-		if value <= *node.Value && node.Left != nil {
-			return predicate(node.Left, input)
-		} else if node.Right != nil {
-			return predicate(node.Right, input)
-		}
-	default:
-		if value == *node.Value && node.Left != nil {
-			return predicate(node.Left, input)
-		} else if node.Right != nil {
-			return predicate(node.Right, input)
-		}
+	if node.goesLeft(input) && node.Left != nil {
+		return predicate(node.Left, input)
+	} else if node.Right != nil {
+		return predicate(node.Right, input)
 	}
 
 	return 0
 }
 
+func (node *RegressionNode[F]) isLeaf() bool {
+	return node.Value == nil && node.Categories == nil
+}
+
+func (node *RegressionNode[F]) goesLeft(input []F) bool {
+	value := input[node.Column]
+	if isMissing(value, node.Missing) {
+		return missingGoesLeft(node.Surrogates, input, node.MissingLeft)
+	}
+	return testLeft(node.columnType(value), node.Value, node.Categories, value)
+}
+
+// children returns the child the input follows first and the other one
+// second.
+func (node *RegressionNode[F]) children(input []F) (*RegressionNode[F], *RegressionNode[F]) {
+	if node.goesLeft(input) {
+		return node.Left, node.Right
+	}
+	return node.Right, node.Left
+}
+
+// expectation returns the leaf labels averaged by leaf size, the prediction
+// expected without knowing the input.
+func (node *RegressionNode[F]) expectation() float64 {
+	return node.expectations(nil)
+}
+
+// expectations computes the expectation of node bottom up and, unless cache
+// is nil, stores that of every node below it on the way.
+func (node *RegressionNode[F]) expectations(cache map[*RegressionNode[F]]float64) float64 {
+	if node == nil {
+		return 0
+	}
+	result := node.Label
+	if !node.isLeaf() {
+		left, right := coverShares(float64(node.Left.Size), float64(node.Right.Size))
+		result = left*node.Left.expectations(cache) + right*node.Right.expectations(cache)
+	}
+	if cache != nil {
+		cache[node] = result
+	}
+	return result
+}
+
+func (node *RegressionNode[F]) columnType(value F) ColumnType {
+	if node.Type == AUTO {
+		return inferColumnType(value)
+	}
+	return node.Type
+}
+
+// inferTypes sets the column types of splits decoded from dumps that predate
+// them, which split on the Go type of the feature.
+func (node *RegressionNode[F]) inferTypes() {
+	if node == nil || node.isLeaf() {
+		return
+	}
+	if node.Value != nil {
+		node.Type = inferColumnType(*node.Value)
+	}
+	node.Left.inferTypes()
+	node.Right.inferTypes()
+}
+
 func BuildTree[F Feature](inputs [][]F, labels []float64, samples_count, selected_feature_count, maxDepth int) *RegressionTree[F] {
+	return BuildSeededTree(inputs, labels, samples_count, selected_feature_count, maxDepth, rand.Int63())
+}
 
+func BuildSeededTree[F Feature](inputs [][]F, labels []float64, samples_count, selected_feature_count, maxDepth int, seed int64) *RegressionTree[F] {
+	rng := rand.New(rand.NewSource(seed))
 	samples := make([][]F, samples_count)
 	samples_labels := make([]float64, samples_count)
 	for i := 0; i < samples_count; i++ {
-		j := int(rand.Float64() * float64(len(inputs)))
+		j := int(rng.Float64() * float64(len(inputs)))
 		samples[i] = inputs[j]
 		samples_labels[i] = labels[j]
 	}
 
 	tree := &RegressionTree[F]{}
-	tree.Root = buildRegressionNode(samples, samples_labels, selected_feature_count, maxDepth)
+	cfg := &growConfig[F]{ctx: context.Background(), rng: rng, mFeatures: selected_feature_count}
+	tree.Root = buildRegressionNode(cfg, samples, allRows(len(samples)), samples_labels, maxDepth)
 
 	return tree
 }
@@ -236,3 +341,18 @@ func BuildTree[F Feature](inputs [][]F, labels []float64, samples_count, selecte
 func (tree *RegressionTree[F]) Predicate(input []F) float64 {
 	return predicate(tree.Root, input)
 }
+
+func (tree *RegressionTree[F]) Nodes() int {
+	return tree.Root.nodes()
+}
+
+func (tree *RegressionTree[F]) oob() float64 {
+	return tree.Validation
+}
+
+func (node *RegressionNode[F]) nodes() int {
+	if node == nil {
+		return 0
+	}
+	return 1 + node.Left.nodes() + node.Right.nodes()
+}
@@ -0,0 +1,188 @@
+package randomForest
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+)
+
+func inferColumnType[F Feature](value F) ColumnType {
+	if reflect.TypeOf(value) == reflect.TypeFor[float64]() {
+		return NUMERIC
+	}
+	return CAT
+}
+
+// resolveColumnType returns the type of column c from schema, falling back
+// to the Go type of the feature for AUTO or missing entries.
+func resolveColumnType[F Feature](schema []ColumnType, c int, value F) ColumnType {
+	if c < len(schema) && schema[c] != AUTO {
+		return schema[c]
+	}
+	return inferColumnType(value)
+}
+
+func (forest *BaseForest[F]) validateSchema(features int) error {
+	if forest.Schema != nil && len(forest.Schema) != features {
+		return fmt.Errorf("%w: schema has %d columns, rows have %d", ErrSchemaMismatch, len(forest.Schema), features)
+	}
+	return nil
+}
+
+// Row is a feature row whose columns may hold different Go types, e.g.
+// strings next to numbers. A nil cell is a missing value.
+type Row []any
+
+// RowEncoder turns Rows into float64 feature rows for a forest with
+// Feature float64. Numeric columns keep their value, categorical columns
+// are replaced by a per-column category code. Encode learns unseen
+// categories and is meant for training data; Transform only looks them up
+// and is meant for prediction. Transform is safe for concurrent use as long
+// as Encode is not running.
+type RowEncoder struct {
+	Schema     []ColumnType
+	Categories [][]string
+	codes      []map[string]float64
+}
+
+func NewRowEncoder(schema ...ColumnType) *RowEncoder {
+	return &RowEncoder{
+		Schema:     schema,
+		Categories: make([][]string, len(schema)),
+	}
+}
+
+// Encode encodes row, assigning the next code to categories it has not
+// seen before.
+func (e *RowEncoder) Encode(row Row) ([]float64, error) {
+	return e.encodeRow(row, e.code)
+}
+
+// Transform encodes row like Encode, but categories unseen by Encode become
+// missing (NaN) instead of getting a code the forest was never trained on.
+func (e *RowEncoder) Transform(row Row) ([]float64, error) {
+	return e.encodeRow(row, e.lookup)
+}
+
+func (e *RowEncoder) encodeRow(row Row, code func(c int, category string) float64) ([]float64, error) {
+	if len(row) != len(e.Schema) {
+		return nil, fmt.Errorf("%w: row has %d columns, schema has %d", ErrSchemaMismatch, len(row), len(e.Schema))
+	}
+	out := make([]float64, len(row))
+	for c, v := range row {
+		if v == nil {
+			out[c] = math.NaN()
+			continue
+		}
+		switch e.Schema[c] {
+		case NUMERIC:
+			f, ok := toFloat(v)
+			if !ok {
+				return nil, fmt.Errorf("%w: column %d is numeric, got %T", ErrSchemaMismatch, c, v)
+			}
+			out[c] = f
+		case CAT:
+			out[c] = code(c, fmt.Sprint(v))
+		default:
+			return nil, fmt.Errorf("%w: column %d has no type", ErrSchemaMismatch, c)
+		}
+	}
+	return out, nil
+}
+
+func (e *RowEncoder) EncodeAll(rows []Row) ([][]float64, error) {
+	return e.all(rows, e.Encode)
+}
+
+func (e *RowEncoder) TransformAll(rows []Row) ([][]float64, error) {
+	return e.all(rows, e.Transform)
+}
+
+func (e *RowEncoder) all(rows []Row, encode func(Row) ([]float64, error)) ([][]float64, error) {
+	out := make([][]float64, len(rows))
+	for i, row := range rows {
+		encoded, err := encode(row)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		out[i] = encoded
+	}
+	return out, nil
+}
+
+// Category returns the category behind code in column c.
+func (e *RowEncoder) Category(c int, code float64) (string, bool) {
+	i := int(code)
+	if c >= len(e.Categories) || float64(i) != code || i < 0 || i >= len(e.Categories[c]) {
+		return "", false
+	}
+	return e.Categories[c][i], true
+}
+
+func (e *RowEncoder) code(c int, category string) float64 {
+	if e.codes == nil {
+		e.codes = make([]map[string]float64, len(e.Schema))
+	}
+	if e.codes[c] == nil {
+		e.codes[c] = make(map[string]float64, len(e.Categories[c]))
+		for i, name := range e.Categories[c] {
+			e.codes[c][name] = float64(i)
+		}
+	}
+	if code, ok := e.codes[c][category]; ok {
+		return code
+	}
+	code := float64(len(e.Categories[c]))
+	e.Categories[c] = append(e.Categories[c], category)
+	e.codes[c][category] = code
+	return code
+}
+
+// lookup returns the code of a known category or NaN. It falls back to a
+// linear search of Categories when Encode has not indexed the column, so it
+// never writes to the encoder.
+func (e *RowEncoder) lookup(c int, category string) float64 {
+	if e.codes != nil && e.codes[c] != nil {
+		if code, ok := e.codes[c][category]; ok {
+			return code
+		}
+		return math.NaN()
+	}
+	if c < len(e.Categories) {
+		if i := slices.Index(e.Categories[c], category); i >= 0 {
+			return float64(i)
+		}
+	}
+	return math.NaN()
+}
+
+func toFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case int8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case uint16:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	}
+	return 0, false
+}
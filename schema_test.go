@@ -0,0 +1,104 @@
+package randomForest
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestColumnTypeValues(t *testing.T) {
+	if CAT != 0 || NUMERIC != 1 {
+		t.Fatalf("CAT = %d, NUMERIC = %d, want 0 and 1", CAT, NUMERIC)
+	}
+}
+
+// mixedIris returns iris rows with the petal width as a string category next
+// to the three other measurements.
+func mixedIris(t testing.TB) ([]Row, []string) {
+	x, y := loadIris(t)
+	rows := make([]Row, len(x))
+	for i, r := range x {
+		rows[i] = Row{r[0], r[1], r[2], strings.Repeat("w", int(r[3]*2))}
+	}
+	return rows, y
+}
+
+func TestTransformUnknownCategory(t *testing.T) {
+	enc := NewRowEncoder(NUMERIC, CAT)
+	if _, err := enc.EncodeAll([]Row{{1.0, "a"}, {2, "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := enc.Transform(Row{3, "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out[0] != 3 || !math.IsNaN(out[1]) {
+		t.Fatalf("got %v, want [3 NaN]", out)
+	}
+	if out, _ := enc.Transform(Row{nil, "b"}); !math.IsNaN(out[0]) || out[1] != 1 {
+		t.Fatalf("got %v, want [NaN 1]", out)
+	}
+	if !reflect.DeepEqual(enc.Categories[1], []string{"a", "b"}) {
+		t.Fatalf("Transform learned categories: %v", enc.Categories[1])
+	}
+}
+
+func TestEncoderSaved(t *testing.T) {
+	rows, y := mixedIris(t)
+	enc := NewRowEncoder(NUMERIC, NUMERIC, NUMERIC, CAT)
+	x, err := enc.EncodeAll(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forest := NewClassificationForest[float64, string](1000, 10, 1, 0.5)
+	forest.MaxDepth = 10
+	forest.Seed = 1
+	forest.Schema = enc.Schema
+	forest.Encoder = enc
+	if err := forest.TryTrain(x, y, 10); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := forest.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &ClassificationForest[float64, string]{}
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Encoder == nil || !reflect.DeepEqual(loaded.Encoder.Schema, enc.Schema) || !reflect.DeepEqual(loaded.Encoder.Categories, enc.Categories) {
+		t.Fatalf("encoder not restored: %+v", loaded.Encoder)
+	}
+	for i, row := range rows {
+		input, err := loaded.Encoder.Transform(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := loaded.Predicate(input), forest.Predicate(x[i]); got != want {
+			t.Fatalf("row %d: got %v after loading, want %v", i, got, want)
+		}
+	}
+}
+
+// A dump written before column types existed split float64 columns with
+// "<=" and every other feature type with "==".
+func TestLegacyDumpInfersTypes(t *testing.T) {
+	dump := `{"Features":1,"Trees":[{"Root":{"Size":2,"Value":1.5,"Column":0,
+		"Left":{"Size":1,"Labels":{"a":1}},"Right":{"Size":1,"Labels":{"b":1}}}}],"Classes":2}`
+	forest := &ClassificationForest[float64, string]{}
+	if err := forest.Load(strings.NewReader(dump)); err != nil {
+		t.Fatal(err)
+	}
+	if got := forest.Predicate([]float64{1}); got != "a" {
+		t.Fatalf("got %q for 1 <= 1.5, want a", got)
+	}
+	categorical := &ClassificationForest[int, string]{}
+	if err := categorical.Load(strings.NewReader(strings.Replace(dump, "1.5", "1", 1))); err != nil {
+		t.Fatal(err)
+	}
+	if got := categorical.Predicate([]int{0}); got != "b" {
+		t.Fatalf("got %q for 0 == 1, want b", got)
+	}
+}
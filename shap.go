@@ -0,0 +1,187 @@
+package randomForest
+
+// Contributions splits a prediction into a per-feature part: Expected plus
+// the sum of Values equals the predicted value.
+type Contributions struct {
+	Expected float64
+	Values   []float64
+}
+
+type pathElement struct {
+	column int
+	zero   float64
+	one    float64
+	weight float64
+}
+
+// treeShap implements the path-dependent TreeSHAP algorithm of Lundberg et
+// al. over any node type. Covers are the training sample counts of the
+// nodes, weighed against each other by coverShares; leaf receives every leaf
+// together with the feature and the factor its value has to be credited
+// with.
+type treeShap[N any] struct {
+	split func(node N) (hot, cold N, column int, leaf bool)
+	cover func(node N) float64
+	leaf  func(node N, column int, scale float64)
+}
+
+func (t *treeShap[N]) run(root N) {
+	t.recurse(root, nil, 1, 1, -1)
+}
+
+func (t *treeShap[N]) recurse(node N, parent []pathElement, zero, one float64, column int) {
+	path := make([]pathElement, len(parent), len(parent)+1)
+	copy(path, parent)
+	path = extendPath(path, zero, one, column)
+
+	hot, cold, split_column, leaf := t.split(node)
+	if leaf {
+		for i := 1; i < len(path); i++ {
+			w := unwoundPathSum(path, i)
+			t.leaf(node, path[i].column, w*(path[i].one-path[i].zero))
+		}
+		return
+	}
+
+	incoming_zero, incoming_one := 1.0, 1.0
+	for k := 1; k < len(path); k++ {
+		if path[k].column == split_column {
+			incoming_zero, incoming_one = path[k].zero, path[k].one
+			path = unwindPath(path, k)
+			break
+		}
+	}
+	hot_share, cold_share := coverShares(t.cover(hot), t.cover(cold))
+	t.recurse(hot, path, hot_share*incoming_zero, incoming_one, split_column)
+	t.recurse(cold, path, cold_share*incoming_zero, 0, split_column)
+}
+
+// coverShares returns the fractions of the rows of a split that reach each
+// child. Imported trees may carry no sizes; their children count equally.
+func coverShares(a, b float64) (float64, float64) {
+	if a+b <= 0 {
+		return 0.5, 0.5
+	}
+	return a / (a + b), b / (a + b)
+}
+
+func extendPath(path []pathElement, zero, one float64, column int) []pathElement {
+	depth := len(path)
+	e := pathElement{column: column, zero: zero, one: one}
+	if depth == 0 {
+		e.weight = 1
+	}
+	path = append(path, e)
+	for i := depth - 1; i >= 0; i-- {
+		path[i+1].weight += one * path[i].weight * float64(i+1) / float64(depth+1)
+		path[i].weight = zero * path[i].weight * float64(depth-i) / float64(depth+1)
+	}
+	return path
+}
+
+func unwindPath(path []pathElement, k int) []pathElement {
+	depth := len(path) - 1
+	one, zero := path[k].one, path[k].zero
+	next := path[depth].weight
+	for i := depth - 1; i >= 0; i-- {
+		if one != 0 {
+			tmp := path[i].weight
+			path[i].weight = next * float64(depth+1) / (float64(i+1) * one)
+			next = tmp - path[i].weight*zero*float64(depth-i)/float64(depth+1)
+		} else if zero != 0 {
+			path[i].weight = path[i].weight * float64(depth+1) / (zero * float64(depth-i))
+		}
+	}
+	for i := k; i < depth; i++ {
+		path[i].column, path[i].zero, path[i].one = path[i+1].column, path[i+1].zero, path[i+1].one
+	}
+	return path[:depth]
+}
+
+func unwoundPathSum(path []pathElement, k int) float64 {
+	depth := len(path) - 1
+	one, zero := path[k].one, path[k].zero
+	next := path[depth].weight
+	total := 0.0
+	for i := depth - 1; i >= 0; i-- {
+		if one != 0 {
+			tmp := next * float64(depth+1) / (float64(i+1) * one)
+			total += tmp
+			next = path[i].weight - tmp*zero*float64(depth-i)/float64(depth+1)
+		} else if zero != 0 {
+			total += path[i].weight / zero / (float64(depth-i) / float64(depth+1))
+		}
+	}
+	return total
+}
+
+// SHAP returns the TreeSHAP contributions of every feature to
+// PredicateWithData(input), per class.
+func (forest *ClassificationForest[F, L]) SHAP(input []F) map[L]Contributions {
+	result := make(map[L]Contributions)
+	class := func(l L) Contributions {
+		c, ok := result[l]
+		if !ok {
+			c = Contributions{Values: make([]float64, len(input))}
+		}
+		return c
+	}
+	for _, tree := range forest.Trees {
+		t := &treeShap[*ClassificationNode[F, L]]{
+			split: func(node *ClassificationNode[F, L]) (*ClassificationNode[F, L], *ClassificationNode[F, L], int, bool) {
+				if node.isLeaf() {
+					return nil, nil, 0, true
+				}
+				hot, cold := node.children(input)
+				return hot, cold, node.Column, false
+			},
+			cover: func(node *ClassificationNode[F, L]) float64 {
+				return float64(node.Size)
+			},
+			leaf: func(node *ClassificationNode[F, L], column int, scale float64) {
+				for l, v := range node.distribution() {
+					c := class(l)
+					c.Values[column] += scale * v
+					result[l] = c
+				}
+			},
+		}
+		t.run(tree.Root)
+		for l, v := range tree.Root.expectation() {
+			c := class(l)
+			c.Expected += v
+			result[l] = c
+		}
+	}
+	return result
+}
+
+// SHAP returns the TreeSHAP contributions of every feature to
+// Predicate(input).
+func (forest *RegressionForest[F]) SHAP(input []F) Contributions {
+	result := Contributions{Values: make([]float64, len(input))}
+	if len(forest.Trees) == 0 {
+		return result
+	}
+	n := float64(len(forest.Trees))
+	for _, tree := range forest.Trees {
+		t := &treeShap[*RegressionNode[F]]{
+			split: func(node *RegressionNode[F]) (*RegressionNode[F], *RegressionNode[F], int, bool) {
+				if node.isLeaf() {
+					return nil, nil, 0, true
+				}
+				hot, cold := node.children(input)
+				return hot, cold, node.Column, false
+			},
+			cover: func(node *RegressionNode[F]) float64 {
+				return float64(node.Size)
+			},
+			leaf: func(node *RegressionNode[F], column int, scale float64) {
+				result.Values[column] += scale * node.Label / n
+			},
+		}
+		t.run(tree.Root)
+		result.Expected += tree.Root.expectation() / n
+	}
+	return result
+}
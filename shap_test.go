@@ -0,0 +1,92 @@
+package randomForest
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// checkClassSHAP checks that the contributions of each class plus its
+// expected value add up to PredicateWithData.
+func checkClassSHAP[F Feature, L Label](t *testing.T, forest *ClassificationForest[F, L], inputs [][]F) {
+	t.Helper()
+	for i, input := range inputs {
+		shap := forest.SHAP(input)
+		votes := forest.PredicateWithData(input)
+		for l := range votes {
+			if _, ok := shap[l]; !ok {
+				t.Fatalf("row %d: no contributions for %v", i, l)
+			}
+		}
+		for l, c := range shap {
+			if got := c.Expected + sum(c.Values); math.Abs(got-votes[l]) > 1e-9 {
+				t.Fatalf("row %d class %v: expected value plus contributions %v, PredicateWithData %v", i, l, got, votes[l])
+			}
+		}
+	}
+}
+
+func checkRegressionSHAP[F Feature](t *testing.T, forest *RegressionForest[F], inputs [][]F) {
+	t.Helper()
+	for i, input := range inputs {
+		shap := forest.SHAP(input)
+		if got, want := shap.Expected+sum(shap.Values), forest.Predicate(input); math.Abs(got-want) > 1e-9 {
+			t.Fatalf("row %d: expected value plus contributions %v, Predicate %v", i, got, want)
+		}
+	}
+}
+
+func TestSHAPSumsToPrediction(t *testing.T) {
+	x, y := loadIris(t)
+	checkClassSHAP(t, trainedIris(t, x, y), x)
+	forest, inputs := trainedSin(t)
+	checkRegressionSHAP(t, forest, inputs[:200])
+}
+
+func TestSHAPSumsToPredictionMissing(t *testing.T) {
+	x, y := loadIris(t)
+	x = blank(x, 0.2, math.NaN())
+	checkClassSHAP(t, trainedIris(t, x, y), x)
+
+	labels := make([]float64, len(x))
+	for i := range x {
+		labels[i] = float64(i)
+	}
+	forest := NewRegressionForest[float64](1000, 20, 1, 1)
+	forest.MaxSurrogates = 2
+	forest.Seed = 1
+	if err := forest.TryTrain(x, labels, 20); err != nil {
+		t.Fatal(err)
+	}
+	checkRegressionSHAP(t, forest, x)
+}
+
+// Trees imported without sample counts have nodes of size 0, which count as
+// equal halves.
+func TestSHAPWithoutSizes(t *testing.T) {
+	regressor, err := ReadSklearnRegressionForest[float64](strings.NewReader(`{"n_features": 1, "estimators": [
+		{"children_left": [1, -1, -1], "children_right": [2, -1, -1], "feature": [0, -2, -2], "threshold": [0.5, -2, -2], "value": [[[2]], [[1]], [[3]]]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shap := regressor.SHAP([]float64{0})
+	if shap.Expected != 2 || shap.Values[0] != -1 {
+		t.Fatalf("got %+v, want expected value 2 and contribution -1", shap)
+	}
+	if e := regressor.Explain([]float64{0}); e.Bias != 2 || e.Contributions[0] != -1 {
+		t.Fatalf("Explain gives bias %v and contributions %v, want 2 and [-1]", e.Bias, e.Contributions)
+	}
+	classifier, err := ReadSklearnForest[float64, string](strings.NewReader(strings.Replace(sklearnClassifier, `"n_node_samples": [150, 50, 100, 54, 46],`, "", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkClassSHAP(t, classifier, [][]float64{{5.1, 3.5, 1.4, 0.2}, {6.0, 2.9, 4.5, 1.5}, {6.3, 3.3, 6.0, 2.5}})
+}
@@ -0,0 +1,261 @@
+package randomForest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// sklearnTree holds the arrays of a fitted scikit-learn tree_ attribute.
+// Node k is a leaf if children_left[k] is -1.
+type sklearnTree struct {
+	ChildrenLeft    []int             `json:"children_left"`
+	ChildrenRight   []int             `json:"children_right"`
+	Feature         []int             `json:"feature"`
+	Threshold       []float64         `json:"threshold"`
+	Value           []json.RawMessage `json:"value"`
+	NNodeSamples    []int             `json:"n_node_samples"`
+	MissingGoToLeft []int             `json:"missing_go_to_left"`
+}
+
+type sklearnForest struct {
+	NFeatures   int               `json:"n_features"`
+	NFeaturesIn int               `json:"n_features_in_"`
+	Classes     []json.RawMessage `json:"classes"`
+	Estimators  []sklearnTree     `json:"estimators"`
+}
+
+// readSklearn decodes a scikit-learn dump and checks the shape of its trees.
+func readSklearn(r io.Reader) (*sklearnForest, error) {
+	var dump sklearnForest
+	if err := json.NewDecoder(r).Decode(&dump); err != nil {
+		return nil, corruptModel(err)
+	}
+	if dump.NFeatures == 0 {
+		dump.NFeatures = dump.NFeaturesIn
+	}
+	if len(dump.Estimators) == 0 {
+		return nil, corruptModel(errors.New("no estimators"))
+	}
+	for t, tree := range dump.Estimators {
+		n := len(tree.ChildrenLeft)
+		if n == 0 || len(tree.ChildrenRight) != n || len(tree.Feature) != n || len(tree.Threshold) != n || len(tree.Value) != n ||
+			(tree.NNodeSamples != nil && len(tree.NNodeSamples) != n) || (tree.MissingGoToLeft != nil && len(tree.MissingGoToLeft) != n) {
+			return nil, corruptModel(fmt.Errorf("estimator %d: node arrays differ in length", t))
+		}
+		// scikit-learn numbers nodes depth first, so children come after
+		// their parent and belong to it alone; anything else could loop or
+		// import a shared subtree over and over.
+		parent := make([]bool, n)
+		for k := range n {
+			if tree.ChildrenLeft[k] < 0 {
+				continue
+			}
+			left, right := tree.ChildrenLeft[k], tree.ChildrenRight[k]
+			if left <= k || left >= n || right <= k || right >= n || left == right || parent[left] || parent[right] || tree.Feature[k] < 0 {
+				return nil, corruptModel(fmt.Errorf("estimator %d: bad split at node %d", t, k))
+			}
+			parent[left], parent[right] = true, true
+			if dump.NFeaturesIn == 0 && tree.Feature[k] >= dump.NFeatures {
+				dump.NFeatures = tree.Feature[k] + 1
+			}
+			if tree.Feature[k] >= dump.NFeatures {
+				return nil, corruptModel(fmt.Errorf("estimator %d: feature %d of %d", t, tree.Feature[k], dump.NFeatures))
+			}
+		}
+	}
+	return &dump, nil
+}
+
+// nodeValue returns the values of node k, flattening the single output of
+// the [n_nodes, n_outputs, n_classes] value array.
+func (tree *sklearnTree) nodeValue(k int) ([]float64, error) {
+	var nested [][]float64
+	if err := json.Unmarshal(tree.Value[k], &nested); err == nil {
+		if len(nested) != 1 {
+			return nil, fmt.Errorf("node %d has %d outputs, want 1", k, len(nested))
+		}
+		return nested[0], nil
+	}
+	var flat []float64
+	if err := json.Unmarshal(tree.Value[k], &flat); err != nil {
+		return nil, err
+	}
+	return flat, nil
+}
+
+// threshold converts a split threshold to F for a "<=" test, or for "<" if
+// strict is set.
+func threshold[F Feature](t float64, strict bool) (F, error) {
+	var v F
+	r := reflect.ValueOf(&v).Elem()
+	switch r.Kind() {
+	case reflect.Float64:
+		if strict {
+			t = math.Nextafter(t, math.Inf(-1))
+		}
+		r.SetFloat(t)
+	case reflect.String:
+		return v, fmt.Errorf("%w: numeric split on string features", ErrSchemaMismatch)
+	default:
+		if strict {
+			r.SetInt(int64(math.Ceil(t)) - 1)
+		} else {
+			r.SetInt(int64(math.Floor(t)))
+		}
+	}
+	return v, nil
+}
+
+// importSklearnTree builds the tree rooted at node k.
+func importSklearnTree[F Feature, N any](tree *sklearnTree, k, depth int, leaf func(k int) (N, error), branch func(k int, value F, left, right N) N) (N, error) {
+	var zero N
+	if depth > maxDecodeDepth {
+		return zero, errors.New("tree too deep")
+	}
+	if tree.ChildrenLeft[k] < 0 {
+		return leaf(k)
+	}
+	value, err := threshold[F](tree.Threshold[k], false)
+	if err != nil {
+		return zero, err
+	}
+	left, err := importSklearnTree(tree, tree.ChildrenLeft[k], depth+1, leaf, branch)
+	if err != nil {
+		return zero, err
+	}
+	right, err := importSklearnTree(tree, tree.ChildrenRight[k], depth+1, leaf, branch)
+	if err != nil {
+		return zero, err
+	}
+	return branch(k, value, left, right), nil
+}
+
+func (tree *sklearnTree) size(k int) int {
+	if tree.NNodeSamples == nil {
+		return 0
+	}
+	return tree.NNodeSamples[k]
+}
+
+func (tree *sklearnTree) missingLeft(k int) bool {
+	return tree.MissingGoToLeft != nil && tree.MissingGoToLeft[k] != 0
+}
+
+// jsonLabel parses a class label that is a JSON string or number.
+func jsonLabel[L Label](raw json.RawMessage) (L, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return parseFeature[L](s)
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return *new(L), err
+	}
+	if f, err := n.Float64(); err == nil && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return parseFeature[L](strconv.FormatInt(int64(f), 10))
+	}
+	return parseFeature[L](n.String())
+}
+
+// ReadSklearnForest reads a scikit-learn RandomForestClassifier dumped as
+// JSON: an object with "classes" (classes_), "n_features" (n_features_in_)
+// and "estimators", one object per fitted tree holding the tree_ arrays
+// children_left, children_right, feature, threshold and value, optionally
+// n_node_samples and missing_go_to_left. Without "classes" the labels are
+// the class indices. Like predict_proba, PredictProba averages the
+// normalized leaf values of the trees. Without n_node_samples the nodes have
+// Size 0, and SHAP and Explain weigh both children of a split equally.
+func ReadSklearnForest[F Feature, L Label](r io.Reader) (*ClassificationForest[F, L], error) {
+	dump, err := readSklearn(r)
+	if err != nil {
+		return nil, err
+	}
+	forest := &ClassificationForest[F, L]{BaseForest: &BaseForest[F]{Features: dump.NFeatures}}
+	var labels []L
+	for _, raw := range dump.Classes {
+		l, err := jsonLabel[L](raw)
+		if err != nil {
+			return nil, corruptModel(err)
+		}
+		labels = append(labels, l)
+	}
+	classes := make(map[L]bool)
+	for t := range dump.Estimators {
+		tree := &dump.Estimators[t]
+		leaf := func(k int) (*ClassificationNode[F, L], error) {
+			values, err := tree.nodeValue(k)
+			if err != nil {
+				return nil, err
+			}
+			if labels != nil && len(values) != len(labels) {
+				return nil, fmt.Errorf("node %d has %d class values, want %d", k, len(values), len(labels))
+			}
+			node := &ClassificationNode[F, L]{Size: tree.size(k), Labels: make(map[L]float64)}
+			for c, v := range values {
+				if v <= 0 {
+					continue
+				}
+				var l L
+				if labels != nil {
+					l = labels[c]
+				} else if l, err = parseFeature[L](strconv.Itoa(c)); err != nil {
+					return nil, err
+				}
+				node.Labels[l] = v
+				classes[l] = true
+			}
+			if len(node.Labels) == 0 {
+				return nil, fmt.Errorf("leaf %d without class values", k)
+			}
+			return node, nil
+		}
+		branch := func(k int, value F, left, right *ClassificationNode[F, L]) *ClassificationNode[F, L] {
+			return &ClassificationNode[F, L]{Size: tree.size(k), Column: tree.Feature[k], Type: NUMERIC, Value: &value, MissingLeft: tree.missingLeft(k), Left: left, Right: right}
+		}
+		root, err := importSklearnTree(tree, 0, 0, leaf, branch)
+		if err != nil {
+			return nil, corruptModel(fmt.Errorf("estimator %d: %w", t, err))
+		}
+		forest.Trees = append(forest.Trees, &ClassificationTree[F, L]{Root: root})
+	}
+	forest.Classes = len(classes)
+	return forest, nil
+}
+
+// ReadSklearnRegressionForest reads a scikit-learn RandomForestRegressor
+// dumped as JSON like ReadSklearnForest. Leaf values must hold a single
+// output.
+func ReadSklearnRegressionForest[F Feature](r io.Reader) (*RegressionForest[F], error) {
+	dump, err := readSklearn(r)
+	if err != nil {
+		return nil, err
+	}
+	forest := &RegressionForest[F]{BaseForest: &BaseForest[F]{Features: dump.NFeatures}}
+	for t := range dump.Estimators {
+		tree := &dump.Estimators[t]
+		leaf := func(k int) (*RegressionNode[F], error) {
+			values, err := tree.nodeValue(k)
+			if err != nil {
+				return nil, err
+			}
+			if len(values) != 1 {
+				return nil, fmt.Errorf("node %d has %d values, want 1", k, len(values))
+			}
+			return &RegressionNode[F]{Size: tree.size(k), Label: values[0]}, nil
+		}
+		branch := func(k int, value F, left, right *RegressionNode[F]) *RegressionNode[F] {
+			return &RegressionNode[F]{Size: tree.size(k), Column: tree.Feature[k], Type: NUMERIC, Value: &value, MissingLeft: tree.missingLeft(k), Left: left, Right: right}
+		}
+		root, err := importSklearnTree(tree, 0, 0, leaf, branch)
+		if err != nil {
+			return nil, corruptModel(fmt.Errorf("estimator %d: %w", t, err))
+		}
+		forest.Trees = append(forest.Trees, &RegressionTree[F]{Root: root})
+	}
+	return forest, nil
+}
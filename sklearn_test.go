@@ -0,0 +1,105 @@
+package randomForest
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+// sklearnClassifier is a two-tree RandomForestClassifier on iris; the first
+// tree holds sample counts, the second fractions.
+const sklearnClassifier = `{"classes": ["setosa", "versicolor", "virginica"], "n_features": 4, "estimators": [
+ {"children_left": [1, -1, 3, -1, -1], "children_right": [2, -1, 4, -1, -1], "feature": [2, -2, 3, -2, -2],
+  "threshold": [2.45, -2, 1.75, -2, -2], "n_node_samples": [150, 50, 100, 54, 46],
+  "value": [[[50, 50, 50]], [[50, 0, 0]], [[0, 50, 50]], [[0, 49, 5]], [[0, 1, 45]]]},
+ {"children_left": [1, -1, -1], "children_right": [2, -1, -1], "feature": [3, -2, -2], "threshold": [0.8, -2, -2],
+  "value": [[[0.33, 0.33, 0.34]], [[1, 0, 0]], [[0, 0.5, 0.5]]]}]}`
+
+const sklearnRegressor = `{"n_features_in_": 2, "estimators": [
+ {"children_left": [1, -1, -1], "children_right": [2, -1, -1], "feature": [0, -2, -2], "threshold": [0.5, -2, -2],
+  "value": [[[2]], [[1]], [[3]]], "missing_go_to_left": [1, 0, 0]},
+ {"children_left": [-1], "children_right": [-1], "feature": [-2], "threshold": [-2], "value": [[[10]]]}]}`
+
+func TestSklearnClassifier(t *testing.T) {
+	forest, err := ReadSklearnForest[float64, string](strings.NewReader(sklearnClassifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := []struct {
+		input []float64
+		want  string
+		proba map[string]float64
+	}{
+		{[]float64{5.1, 3.5, 1.4, 0.2}, "setosa", map[string]float64{"setosa": 1}},
+		{[]float64{6.0, 2.9, 4.5, 1.5}, "versicolor", map[string]float64{"versicolor": (49.0/54 + 0.5) / 2, "virginica": (5.0/54 + 0.5) / 2}},
+		{[]float64{6.3, 3.3, 6.0, 2.5}, "virginica", map[string]float64{"versicolor": (1.0/46 + 0.5) / 2, "virginica": (45.0/46 + 0.5) / 2}},
+	}
+	for _, g := range golden {
+		if got := forest.Predicate(g.input); got != g.want {
+			t.Fatalf("%v: got %v, want %v", g.input, got, g.want)
+		}
+		proba := forest.PredictProba(g.input)
+		for _, l := range []string{"setosa", "versicolor", "virginica"} {
+			if math.Abs(proba[l]-g.proba[l]) > 1e-12 {
+				t.Fatalf("%v: got %v, want %v", g.input, proba, g.proba)
+			}
+		}
+	}
+	// Without classes the labels are the class indices.
+	indexed, err := ReadSklearnForest[float64, int](strings.NewReader(strings.Replace(sklearnClassifier, `"classes": ["setosa", "versicolor", "virginica"], `, "", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := indexed.Predicate([]float64{6.3, 3.3, 6.0, 2.5}); got != 2 {
+		t.Fatalf("got %v, want 2", got)
+	}
+}
+
+func TestSklearnRegressor(t *testing.T) {
+	forest, err := ReadSklearnRegressionForest[float64](strings.NewReader(sklearnRegressor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := []struct {
+		input []float64
+		want  float64
+	}{
+		{[]float64{0.2, 0}, 5.5},
+		{[]float64{0.5, 0}, 5.5},
+		{[]float64{0.7, 0}, 6.5},
+		{[]float64{math.NaN(), 0}, 5.5},
+	}
+	for _, g := range golden {
+		if got := forest.Predicate(g.input); got != g.want {
+			t.Fatalf("%v: got %v, want %v", g.input, got, g.want)
+		}
+	}
+	integer, err := ReadSklearnRegressionForest[int](strings.NewReader(sklearnRegressor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := integer.Predicate([]int{0, 0}); got != 5.5 {
+		t.Fatalf("got %v for 0 <= 0.5, want 5.5", got)
+	}
+	if got := integer.Predicate([]int{1, 0}); got != 6.5 {
+		t.Fatalf("got %v for 1 > 0.5, want 6.5", got)
+	}
+}
+
+// A child pointing back at its parent or at itself must not send the import
+// round in circles, nor may two splits share a subtree.
+func TestSklearnRejectsCycles(t *testing.T) {
+	for _, children := range []string{
+		`"children_left": [1, 0, -1], "children_right": [2, 2, -1]`,
+		`"children_left": [0, -1, -1], "children_right": [2, -1, -1]`,
+		`"children_left": [1, 2, -1], "children_right": [2, 1, -1]`,
+		`"children_left": [1, 2, -1], "children_right": [2, -1, -1]`,
+		`"children_left": [1, 2, -1], "children_right": [1, 2, -1]`,
+	} {
+		dump := `{"n_features": 1, "estimators": [{` + children + `, "feature": [0, 0, -2], "threshold": [1, 1, -2], "value": [[[1]], [[2]], [[3]]]}]}`
+		if _, err := ReadSklearnRegressionForest[float64](strings.NewReader(dump)); !errors.Is(err, ErrCorruptModel) {
+			t.Fatalf("%s: got %v, want %v", children, err, ErrCorruptModel)
+		}
+	}
+}
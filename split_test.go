@@ -0,0 +1,282 @@
+package randomForest
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// rescanGain is the original numeric split search: every unique value is
+// scored by rescanning all samples. Values are visited in ascending order and
+// compared with beats, so ties resolve like the sorted sweep.
+func rescanGain[F Feature, L Label](criterion SplitCriterion[L], samples [][]F, c int, samples_labels []L, current_entropy float64) split[F] {
+	best := split[F]{}
+	for _, value := range uniqueValues(samples, c) {
+		map_l := make(map[L]float64)
+		map_r := make(map[L]float64)
+		total_l, total_r := 0, 0
+		for j := range samples {
+			if samples[j][c] <= value {
+				total_l += 1
+				map_l[samples_labels[j]] += 1.0
+			} else {
+				total_r += 1
+				map_r[samples_labels[j]] += 1.0
+			}
+		}
+		p1 := float64(total_r) / float64(len(samples))
+		p2 := float64(total_l) / float64(len(samples))
+		gain := current_entropy - (p1*criterion.Impurity(map_r, total_r) + p2*criterion.Impurity(map_l, total_l))
+		if beats(gain, best.gain) {
+			best = split[F]{gain: gain, value: value, totalL: total_l, totalR: total_r}
+		}
+	}
+	return best
+}
+
+// rescanMSEGain is the original numeric regression split search.
+func rescanMSEGain[F Feature](samples [][]F, c int, samples_labels []float64, current_mse float64) split[F] {
+	best := split[F]{}
+	for _, value := range uniqueValues(samples, c) {
+		labels_l := make([]float64, 0)
+		labels_r := make([]float64, 0)
+		for j := range samples {
+			if samples[j][c] <= value {
+				labels_l = append(labels_l, samples_labels[j])
+			} else {
+				labels_r = append(labels_r, samples_labels[j])
+			}
+		}
+		p1 := float64(len(labels_r)) / float64(len(samples))
+		p2 := float64(len(labels_l)) / float64(len(samples))
+		gain := current_mse - (p1*getMSE(labels_r) + p2*getMSE(labels_l))
+		if beats(gain, best.gain) {
+			best = split[F]{gain: gain, value: value, totalL: len(labels_l), totalR: len(labels_r)}
+		}
+	}
+	return best
+}
+
+func uniqueValues[F Feature](samples [][]F, c int) []F {
+	values := make([]F, len(samples))
+	for j := range samples {
+		values[j] = samples[j][c]
+	}
+	slices.Sort(values)
+	return slices.Compact(values)
+}
+
+// rescanNode grows a classification tree like buildNode on numeric columns,
+// but with rescanGain.
+func rescanNode[F Feature, L Label](rng *rand.Rand, criterion SplitCriterion[L], mFeatures int, samples [][]F, labels []L, depth int) *ClassificationNode[F, L] {
+	counts := make(map[L]float64)
+	for _, l := range labels {
+		counts[l] += 1
+	}
+	current_entropy := criterion.Impurity(counts, len(labels))
+	var best split[F]
+	best_column := -1
+	for _, c := range getRandomRange(rng, len(samples[0]), mFeatures) {
+		if s := rescanGain(criterion, samples, c, labels, current_entropy); beatsColumn(s.gain, c, best.gain, best_column) {
+			best, best_column = s, c
+		}
+	}
+	if best.gain > 0 && best.totalL > 0 && best.totalR > 0 && depth > 0 {
+		best.missingLeft = best.totalL >= best.totalR
+		partL, partR := splitSamples(samples, NUMERIC, best_column, best)
+		return &ClassificationNode[F, L]{
+			Size:        len(labels),
+			Value:       &best.value,
+			Column:      best_column,
+			Type:        NUMERIC,
+			MissingLeft: best.missingLeft,
+			Gain:        best.gain,
+			Left:        rescanNode(rng, criterion, mFeatures, getSamples(samples, partL), getLabels(labels, partL), depth-1),
+			Right:       rescanNode(rng, criterion, mFeatures, getSamples(samples, partR), getLabels(labels, partR), depth-1),
+		}
+	}
+	return genLeafNode[F](criterion, labels)
+}
+
+// rescanRegressionNode grows a regression tree like buildRegressionNode on
+// numeric columns, but with rescanMSEGain.
+func rescanRegressionNode[F Feature](rng *rand.Rand, mFeatures int, samples [][]F, labels []float64, depth int) *RegressionNode[F] {
+	current_mse := getMSE(labels)
+	var best split[F]
+	best_column := -1
+	for _, c := range getRandomRange(rng, len(samples[0]), mFeatures) {
+		if s := rescanMSEGain(samples, c, labels, current_mse); beatsColumn(s.gain, c, best.gain, best_column) {
+			best, best_column = s, c
+		}
+	}
+	if best.gain > 0 && best.totalL > 0 && best.totalR > 0 && depth > 0 {
+		best.missingLeft = best.totalL >= best.totalR
+		partL, partR := splitSamples(samples, NUMERIC, best_column, best)
+		return &RegressionNode[F]{
+			Size:        len(labels),
+			Value:       &best.value,
+			Column:      best_column,
+			Type:        NUMERIC,
+			MissingLeft: best.missingLeft,
+			Gain:        best.gain,
+			Left:        rescanRegressionNode(rng, mFeatures, getSamples(samples, partL), getLabels(labels, partL), depth-1),
+			Right:       rescanRegressionNode(rng, mFeatures, getSamples(samples, partR), getLabels(labels, partR), depth-1),
+		}
+	}
+	return genRegressionLeafNode[F](labels)
+}
+
+// sameSplits returns the path to the first node in preorder where two trees
+// differ in structure, split or leaf labels, or "" if they are the same. Only
+// gains may differ, by rounding below gainTolerance.
+func sameSplits[F Feature](a, b node[F], path string) string {
+	if a.isLeaf() != b.isLeaf() || a.size() != b.size() {
+		return path
+	}
+	if a.isLeaf() {
+		if !reflect.DeepEqual(a.labels(), b.labels()) {
+			return path
+		}
+		return ""
+	}
+	if math.Abs(a.gain()-b.gain()) > gainTolerance || a.column() != b.column() || *a.value() != *b.value() || a.missingLeft() != b.missingLeft() {
+		return path
+	}
+	if diff := sameSplits(a.left(), b.left(), path+"L"); diff != "" {
+		return diff
+	}
+	return sameSplits(a.right(), b.right(), path+"R")
+}
+
+type node[F Feature] interface {
+	isLeaf() bool
+	column() int
+	value() *F
+	size() int
+	missingLeft() bool
+	gain() float64
+	labels() any
+	left() node[F]
+	right() node[F]
+}
+
+type classNode[F Feature, L Label] struct{ *ClassificationNode[F, L] }
+
+func (n classNode[F, L]) column() int       { return n.Column }
+func (n classNode[F, L]) value() *F         { return n.Value }
+func (n classNode[F, L]) size() int         { return n.Size }
+func (n classNode[F, L]) missingLeft() bool { return n.MissingLeft }
+func (n classNode[F, L]) gain() float64     { return n.Gain }
+func (n classNode[F, L]) labels() any       { return n.Labels }
+func (n classNode[F, L]) left() node[F]     { return classNode[F, L]{n.Left} }
+func (n classNode[F, L]) right() node[F]    { return classNode[F, L]{n.Right} }
+
+type regressionNode[F Feature] struct{ *RegressionNode[F] }
+
+func (n regressionNode[F]) column() int       { return n.Column }
+func (n regressionNode[F]) value() *F         { return n.Value }
+func (n regressionNode[F]) size() int         { return n.Size }
+func (n regressionNode[F]) missingLeft() bool { return n.MissingLeft }
+func (n regressionNode[F]) gain() float64     { return n.Gain }
+func (n regressionNode[F]) labels() any       { return n.Label }
+func (n regressionNode[F]) left() node[F]     { return regressionNode[F]{n.Left} }
+func (n regressionNode[F]) right() node[F]    { return regressionNode[F]{n.Right} }
+
+func sinData() ([][]float64, []float64) {
+	x := make([][]float64, 100)
+	y := make([]float64, 100)
+	for i := range x {
+		x[i] = []float64{float64(i) / 20.0}
+		y[i] = math.Sin(x[i][0])
+	}
+	return x, y
+}
+
+func TestSortedSplitsMatchRescanClassification(t *testing.T) {
+	x, y := loadIris(t)
+	for seed := int64(0); seed < 200; seed++ {
+		cfg := &growConfig[float64]{ctx: context.Background(), rng: rand.New(rand.NewSource(seed)), mFeatures: 2}
+		sorted := buildNode(cfg, Entropy[string]{}, x, allRows(len(x)), y, 10)
+		rescan := rescanNode(rand.New(rand.NewSource(seed)), Entropy[string]{}, 2, x, y, 10)
+		if path := sameSplits[float64](classNode[float64, string]{sorted}, classNode[float64, string]{rescan}, "root"); path != "" {
+			t.Fatalf("seed %d: trees differ at %s", seed, path)
+		}
+	}
+}
+
+func TestSortedSplitsMatchRescanRegression(t *testing.T) {
+	iris, _ := loadIris(t)
+	irisLabels := make([]float64, len(iris))
+	for i, row := range iris {
+		irisLabels[i] = row[0]
+		iris[i] = row[1:]
+	}
+	sinX, sinY := sinData()
+	for name, data := range map[string]struct {
+		x [][]float64
+		y []float64
+		m int
+	}{"iris": {iris, irisLabels, 2}, "sin": {sinX, sinY, 1}} {
+		for seed := int64(0); seed < 200; seed++ {
+			cfg := &growConfig[float64]{ctx: context.Background(), rng: rand.New(rand.NewSource(seed)), mFeatures: data.m}
+			sorted := buildRegressionNode(cfg, data.x, allRows(len(data.x)), data.y, 10)
+			rescan := rescanRegressionNode(rand.New(rand.NewSource(seed)), data.m, data.x, data.y, 10)
+			if path := sameSplits[float64](regressionNode[float64]{sorted}, regressionNode[float64]{rescan}, "root"); path != "" {
+				t.Fatalf("%s seed %d: trees differ at %s", name, seed, path)
+			}
+		}
+	}
+}
+
+// benchmarkSplits times the sorted sweep against the rescan on every column.
+func benchmarkSplits[L Label](b *testing.B, x [][]float64, y []L) {
+	counts := make(map[L]float64)
+	for _, l := range y {
+		counts[l] += 1
+	}
+	current := Entropy[L]{}.Impurity(counts, len(y))
+	b.Run("sorted", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for c := range x[0] {
+				getBestNumericGain(Entropy[L]{}, x, c, y, current)
+			}
+		}
+	})
+	b.Run("rescan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for c := range x[0] {
+				rescanGain(Entropy[L]{}, x, c, y, current)
+			}
+		}
+	})
+}
+
+func BenchmarkIris(b *testing.B) {
+	x, y := loadIris(b)
+	benchmarkSplits(b, x, y)
+}
+
+func BenchmarkSin(b *testing.B) {
+	x, y := sinData()
+	current := getMSE(y)
+	b.Run("sorted", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			getBestNumericMSEGain(x, 0, y, current)
+		}
+	})
+	b.Run("rescan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rescanMSEGain(x, 0, y, current)
+		}
+	})
+}
+
+// BenchmarkMNIST searches splits on synthetic digits of the size of the
+// first 2000 MNIST training images.
+func BenchmarkMNIST(b *testing.B) {
+	x, y := syntheticDigits(2000, 784, 1)
+	benchmarkSplits(b, x, y)
+}
@@ -0,0 +1,179 @@
+package randomForest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// XGBoostOptions describe what an XGBoost JSON dump leaves out.
+type XGBoostOptions struct {
+	// Objective is the training objective, e.g. "reg:squarederror",
+	// "binary:logistic" or "multi:softprob".
+	Objective string
+	// Classes is the number of classes of multi: objectives.
+	Classes int
+	// BaseScore is the base_score of the booster in prediction space, 0.5
+	// if nil.
+	BaseScore *float64
+	// FeatureNames maps split names to columns. Without them splits must
+	// name columns as f0, f1, ...
+	FeatureNames []string
+	// Features is the number of columns, by default one more than the
+	// highest column split on.
+	Features int
+}
+
+type xgboostNode struct {
+	NodeID         int           `json:"nodeid"`
+	Split          string        `json:"split"`
+	SplitCondition *float64      `json:"split_condition"`
+	Yes            int           `json:"yes"`
+	No             int           `json:"no"`
+	Missing        *int          `json:"missing"`
+	Leaf           *float64      `json:"leaf"`
+	Cover          float64       `json:"cover"`
+	Categories     []int         `json:"categories"`
+	Children       []xgboostNode `json:"children"`
+}
+
+// xgboostLink returns the link of an objective and the margin of its base
+// score.
+func xgboostLink(objective string, baseScore float64) (Link, float64, error) {
+	switch {
+	case objective == "binary:logistic" || objective == "reg:logistic" || objective == "binary:logitraw":
+		if baseScore <= 0 || baseScore >= 1 {
+			return 0, 0, fmt.Errorf("base score %v outside (0, 1)", baseScore)
+		}
+		link := LOGISTIC
+		if objective == "binary:logitraw" {
+			link = IDENTITY
+		}
+		return link, math.Log(baseScore / (1 - baseScore)), nil
+	case objective == "count:poisson" || objective == "reg:gamma" || objective == "reg:tweedie":
+		if baseScore <= 0 {
+			return 0, 0, fmt.Errorf("base score %v not positive", baseScore)
+		}
+		return EXP, math.Log(baseScore), nil
+	case objective == "multi:softprob" || objective == "multi:softmax":
+		return SOFTMAX, baseScore, nil
+	case strings.HasPrefix(objective, "reg:") || strings.HasPrefix(objective, "rank:"):
+		return IDENTITY, baseScore, nil
+	}
+	return 0, 0, fmt.Errorf("unsupported objective %q", objective)
+}
+
+// ReadXGBoostDump reads the JSON dump of an XGBoost booster, as written by
+// Booster.dump_model or get_dump with dump_format "json": an array with one
+// object per tree. Trees of multi: objectives are assigned to the classes
+// round-robin. Categorical splits are not supported.
+func ReadXGBoostDump[F Feature](r io.Reader, options XGBoostOptions) (*BoostedForest[F], error) {
+	var dump []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&dump); err != nil {
+		return nil, corruptModel(err)
+	}
+	baseScore := 0.5
+	if options.BaseScore != nil {
+		baseScore = *options.BaseScore
+	}
+	link, margin, err := xgboostLink(options.Objective, baseScore)
+	if err != nil {
+		return nil, err
+	}
+	forest := &BoostedForest[F]{Features: options.Features, Outputs: 1, Link: link}
+	if link == SOFTMAX {
+		if options.Classes < 2 {
+			return nil, fmt.Errorf("objective %q needs at least 2 classes, got %d", options.Objective, options.Classes)
+		}
+		forest.Outputs = options.Classes
+	}
+	forest.BaseMargin = make([]float64, forest.Outputs)
+	for k := range forest.BaseMargin {
+		forest.BaseMargin[k] = margin
+	}
+	columns := make(map[string]int, len(options.FeatureNames))
+	for c, name := range options.FeatureNames {
+		columns[name] = c
+	}
+	features := 0
+	column := func(split string) (int, error) {
+		c, ok := columns[split]
+		if !ok {
+			if options.FeatureNames != nil || !strings.HasPrefix(split, "f") {
+				return 0, fmt.Errorf("unknown feature %q", split)
+			}
+			var err error
+			if c, err = strconv.Atoi(split[1:]); err != nil || c < 0 {
+				return 0, fmt.Errorf("unknown feature %q", split)
+			}
+		}
+		if options.Features > 0 && c >= options.Features {
+			return 0, fmt.Errorf("feature %q of %d", split, options.Features)
+		}
+		features = max(features, c+1)
+		return c, nil
+	}
+	var convert func(node *xgboostNode, depth int) (*RegressionNode[F], error)
+	convert = func(node *xgboostNode, depth int) (*RegressionNode[F], error) {
+		if depth > maxDecodeDepth {
+			return nil, errors.New("tree too deep")
+		}
+		if node.Leaf != nil {
+			return &RegressionNode[F]{Size: int(node.Cover), Label: *node.Leaf}, nil
+		}
+		if node.Categories != nil || node.SplitCondition == nil {
+			return nil, fmt.Errorf("node %d: unsupported split", node.NodeID)
+		}
+		c, err := column(node.Split)
+		if err != nil {
+			return nil, err
+		}
+		value, err := threshold[F](*node.SplitCondition, true)
+		if err != nil {
+			return nil, err
+		}
+		split := &RegressionNode[F]{Size: int(node.Cover), Column: c, Type: NUMERIC, Value: &value}
+		split.MissingLeft = node.Missing != nil && *node.Missing == node.Yes
+		for k := range node.Children {
+			child := &node.Children[k]
+			if child.NodeID != node.Yes && child.NodeID != node.No {
+				continue
+			}
+			converted, err := convert(child, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if child.NodeID == node.Yes {
+				split.Left = converted
+			} else {
+				split.Right = converted
+			}
+		}
+		if split.Left == nil || split.Right == nil {
+			return nil, fmt.Errorf("node %d: missing child", node.NodeID)
+		}
+		return split, nil
+	}
+	for t, raw := range dump {
+		var root xgboostNode
+		if err := json.Unmarshal(raw, &root); err != nil {
+			return nil, corruptModel(fmt.Errorf("tree %d: %w", t, err))
+		}
+		node, err := convert(&root, 0)
+		if err != nil {
+			return nil, corruptModel(fmt.Errorf("tree %d: %w", t, err))
+		}
+		forest.Trees = append(forest.Trees, &RegressionTree[F]{Root: node})
+	}
+	if len(forest.Trees) == 0 {
+		return nil, corruptModel(errors.New("no trees"))
+	}
+	if forest.Features == 0 {
+		forest.Features = max(features, len(options.FeatureNames))
+	}
+	return forest, nil
+}
@@ -0,0 +1,72 @@
+package randomForest
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// xgboostBinary is a binary:logistic dump of two trees, the second a single
+// leaf.
+const xgboostBinary = `[
+  { "nodeid": 0, "depth": 0, "split": "f2", "split_condition": 2.45, "yes": 1, "no": 2, "missing": 2, "children": [
+    { "nodeid": 1, "leaf": -0.5 },
+    { "nodeid": 2, "depth": 1, "split": "f3", "split_condition": 1.75, "yes": 3, "no": 4, "missing": 3, "children": [
+      { "nodeid": 3, "leaf": 0.2 },
+      { "nodeid": 4, "leaf": 0.6 }
+    ]}
+  ]},
+  { "nodeid": 0, "leaf": 0.1 }
+]`
+
+func TestXGBoostBinary(t *testing.T) {
+	forest, err := ReadXGBoostDump[float64](strings.NewReader(xgboostBinary), XGBoostOptions{Objective: "binary:logistic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forest.Features != 4 {
+		t.Fatalf("got %d features, want 4", forest.Features)
+	}
+	sigmoid := func(m float64) float64 { return 1 / (1 + math.Exp(-m)) }
+	nan := math.NaN()
+	golden := []struct {
+		input []float64
+		want  float64
+	}{
+		{[]float64{0, 0, 1, 0}, sigmoid(-0.4)},
+		{[]float64{0, 0, 2.45, 0}, sigmoid(0.3)},
+		{[]float64{0, 0, 5, 1}, sigmoid(0.3)},
+		{[]float64{0, 0, 5, 2}, sigmoid(0.7)},
+		{[]float64{0, 0, nan, nan}, sigmoid(0.3)},
+	}
+	for _, g := range golden {
+		if got := forest.Predicate(g.input); math.Abs(got-g.want) > 1e-12 {
+			t.Fatalf("%v: got %v, want %v", g.input, got, g.want)
+		}
+	}
+	if got := forest.PredictClass([]float64{0, 0, 1, 0}); got != 0 {
+		t.Fatalf("got class %d, want 0", got)
+	}
+	if got := forest.PredictClass([]float64{0, 0, 5, 2}); got != 1 {
+		t.Fatalf("got class %d, want 1", got)
+	}
+}
+
+func TestXGBoostRegression(t *testing.T) {
+	zero, one := 0.0, 1.0
+	for _, g := range []struct {
+		baseScore *float64
+		want      float64
+	}{{nil, 0.1}, {&zero, -0.4}, {&one, 0.6}} {
+		forest, err := ReadXGBoostDump[float64](strings.NewReader(xgboostBinary), XGBoostOptions{Objective: "reg:squarederror", BaseScore: g.baseScore})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := forest.Predicate([]float64{0, 0, 1, 0}); math.Abs(got-g.want) > 1e-12 {
+			t.Fatalf("base score %v: got %v, want %v", g.baseScore, got, g.want)
+		}
+	}
+	if _, err := ReadXGBoostDump[float64](strings.NewReader(xgboostBinary), XGBoostOptions{Objective: "multi:softprob"}); err == nil {
+		t.Fatal("read a multi:softprob dump without its number of classes")
+	}
+}